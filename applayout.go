@@ -0,0 +1,253 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/projectmanager"
+	"github.com/bitrise-io/go-xcode/xcodeproject/xcodeproj"
+)
+
+// WatchOS is the platform of the watchOS app and extension targets.
+// The watchOS targets are signed with iOS provisioning profiles, including only the Apple Watch devices.
+const WatchOS autocodesign.Platform = "watchOS"
+
+// platformBySDK maps the SDKROOT build setting to the platform
+var platformBySDK = map[string]autocodesign.Platform{
+	"iphoneos":  autocodesign.IOS,
+	"watchos":   WatchOS,
+	"appletvos": autocodesign.TVOS,
+	"macosx":    autocodesign.MacOS,
+}
+
+// TargetAppLayout is the app layout, knowing the platform of each archivable target.
+type TargetAppLayout struct {
+	autocodesign.AppLayout
+
+	PlatformByBundleID map[string]autocodesign.Platform
+}
+
+// NewTargetAppLayout reads the app layout of the scheme's archivable (and optionally UITest) targets.
+// Unlike projectmanager.Project.GetAppLayout, it supports watch-only apps.
+func NewTargetAppLayout(helper *projectmanager.ProjectHelper, uiTestTargets bool) (TargetAppLayout, error) {
+	log.Printf("Configuration: %s", helper.Configuration)
+
+	platformByBundleID := map[string]autocodesign.Platform{}
+	log.Printf("Application and App Extension targets:")
+	for _, target := range helper.ArchivableTargets() {
+		bundleID, err := helper.TargetBundleID(target.Name, helper.Configuration)
+		if err != nil {
+			return TargetAppLayout{}, fmt.Errorf("failed to get target (%s) bundle id: %s", target.Name, err)
+		}
+
+		platform := targetPlatform(helper.XcProj, target, helper.Configuration)
+		platformByBundleID[bundleID] = platform
+		log.Printf("- %s (%s)", target.Name, platform)
+	}
+
+	platform := WatchOS
+	if targetPlatform(helper.XcProj, helper.MainTarget, helper.Configuration) != WatchOS {
+		var err error
+		platform, err = helper.Platform(helper.Configuration)
+		if err != nil {
+			return TargetAppLayout{}, fmt.Errorf("failed to read project platform: %s", err)
+		}
+	}
+	log.Printf("Platform: %s", platform)
+
+	// Targets with an unknown SDK are signed for the main target's platform
+	for bundleID, targetPlatform := range platformByBundleID {
+		if targetPlatform == "" {
+			platformByBundleID[bundleID] = platform
+		}
+	}
+
+	entitlementsByBundleID, err := helper.ArchivableTargetBundleIDToEntitlements()
+	if err != nil {
+		return TargetAppLayout{}, fmt.Errorf("failed to read archivable targets' entitlements: %s", err)
+	}
+
+	if ok, entitlement, bundleID := projectmanager.CanGenerateProfileWithEntitlements(entitlementsByBundleID); !ok {
+		log.Errorf("Can not create profile with unsupported entitlement (%s) for the bundle ID %s, due to App Store Connect API limitations.", entitlement, bundleID)
+		return TargetAppLayout{}, fmt.Errorf("please generate provisioning profile manually on Apple Developer Portal and use the Certificate and profile installer Step instead")
+	}
+
+	var uiTestTargetBundleIDs []string
+	if uiTestTargets {
+		log.Printf("UITest targets:")
+		for _, target := range helper.UITestTargets {
+			log.Printf("- %s", target.Name)
+		}
+
+		uiTestTargetBundleIDs, err = helper.UITestTargetBundleIDs()
+		if err != nil {
+			return TargetAppLayout{}, fmt.Errorf("failed to read UITest targets' entitlements: %s", err)
+		}
+	}
+
+	return TargetAppLayout{
+		AppLayout: autocodesign.AppLayout{
+			Platform:                               platform,
+			EntitlementsByArchivableTargetBundleID: entitlementsByBundleID,
+			UITestTargetBundleIDs:                  uiTestTargetBundleIDs,
+		},
+		PlatformByBundleID: platformByBundleID,
+	}, nil
+}
+
+// WatchBundleIDs returns the bundle IDs of the watchOS targets.
+func (l TargetAppLayout) WatchBundleIDs() []string {
+	var bundleIDs []string
+	for bundleID, platform := range l.PlatformByBundleID {
+		if platform == WatchOS {
+			bundleIDs = append(bundleIDs, bundleID)
+		}
+	}
+	sort.Strings(bundleIDs)
+	return bundleIDs
+}
+
+// SplitWatchAppLayout returns the app layout of the non-watchOS targets and the app layout of the watchOS targets,
+// either of them is nil if there is no such target.
+// The UITest targets belong to the main target's app layout.
+func (l TargetAppLayout) SplitWatchAppLayout() (*autocodesign.AppLayout, *autocodesign.AppLayout) {
+	if l.Platform == WatchOS {
+		watchAppLayout := l.AppLayout
+		watchAppLayout.Platform = autocodesign.IOS
+		return nil, &watchAppLayout
+	}
+
+	watchBundleIDs := l.WatchBundleIDs()
+	if len(watchBundleIDs) == 0 {
+		appLayout := l.AppLayout
+		return &appLayout, nil
+	}
+
+	appLayout := autocodesign.AppLayout{
+		Platform:                               l.Platform,
+		EntitlementsByArchivableTargetBundleID: map[string]autocodesign.Entitlements{},
+		UITestTargetBundleIDs:                  l.UITestTargetBundleIDs,
+	}
+	watchAppLayout := autocodesign.AppLayout{
+		Platform:                               autocodesign.IOS,
+		EntitlementsByArchivableTargetBundleID: map[string]autocodesign.Entitlements{},
+	}
+	for bundleID, entitlements := range l.EntitlementsByArchivableTargetBundleID {
+		if l.PlatformByBundleID[bundleID] == WatchOS {
+			watchAppLayout.EntitlementsByArchivableTargetBundleID[bundleID] = entitlements
+		} else {
+			appLayout.EntitlementsByArchivableTargetBundleID[bundleID] = entitlements
+		}
+	}
+
+	return &appLayout, &watchAppLayout
+}
+
+// SignedAppLayouts are the app layouts signed in separate passes:
+// the main app layout, the app layout of the watchOS targets and the app layout of the Mac Catalyst variant, if any.
+type SignedAppLayouts struct {
+	Main     *autocodesign.AppLayout
+	Watch    *autocodesign.AppLayout
+	Catalyst *autocodesign.AppLayout
+}
+
+// NewSignedAppLayouts splits the watchOS targets from the app layout, and adds the Mac Catalyst variant if macCatalyst is set.
+func NewSignedAppLayouts(appLayout TargetAppLayout, macCatalyst, derivedBundleIDs bool) (SignedAppLayouts, error) {
+	mainAppLayout, watchAppLayout := appLayout.SplitWatchAppLayout()
+	layouts := SignedAppLayouts{Main: mainAppLayout, Watch: watchAppLayout}
+
+	if macCatalyst {
+		if appLayout.Platform != autocodesign.IOS || mainAppLayout == nil {
+			return SignedAppLayouts{}, fmt.Errorf("Mac Catalyst code signing requires an iOS project, platform: %s", appLayout.Platform)
+		}
+		catalystAppLayout := macCatalystAppLayout(*mainAppLayout, derivedBundleIDs)
+		layouts.Catalyst = &catalystAppLayout
+	}
+
+	return layouts, nil
+}
+
+// All returns the app layouts in signing order.
+func (l SignedAppLayouts) All() []autocodesign.AppLayout {
+	var appLayouts []autocodesign.AppLayout
+	for _, appLayout := range []*autocodesign.AppLayout{l.Main, l.Watch, l.Catalyst} {
+		if appLayout != nil {
+			appLayouts = append(appLayouts, *appLayout)
+		}
+	}
+	return appLayouts
+}
+
+// BundleIDs returns the archivable and UITest target bundle IDs of every app layout.
+func (l SignedAppLayouts) BundleIDs() []string {
+	seen := map[string]bool{}
+	var bundleIDs []string
+	for _, appLayout := range l.All() {
+		for _, bundleID := range bundleIDsOfAppLayout(appLayout) {
+			if !seen[bundleID] {
+				seen[bundleID] = true
+				bundleIDs = append(bundleIDs, bundleID)
+			}
+		}
+	}
+	sort.Strings(bundleIDs)
+	return bundleIDs
+}
+
+// targetPlatform reads the target's platform from its (or the project's) SDKROOT build setting,
+// it returns an empty platform if the SDK is not set or unknown.
+func targetPlatform(xcProj xcodeproj.XcodeProj, target xcodeproj.Target, configuration string) autocodesign.Platform {
+	for _, configurationList := range []xcodeproj.ConfigurationList{target.BuildConfigurationList, xcProj.Proj.BuildConfigurationList} {
+		for _, buildConfiguration := range configurationList.BuildConfigurations {
+			if buildConfiguration.Name != configuration {
+				continue
+			}
+
+			sdk, err := buildConfiguration.BuildSettings.String("SDKROOT")
+			if err != nil {
+				continue
+			}
+			return platformBySDK[sdk]
+		}
+	}
+	return ""
+}
+
+// mergeCodesignAssets adds the profiles of the additional code signing assets to the code signing assets of the same distribution type.
+func mergeCodesignAssets(assetsByDistributionType, additional map[autocodesign.DistributionType]autocodesign.AppCodesignAssets) map[autocodesign.DistributionType]autocodesign.AppCodesignAssets {
+	merged := map[autocodesign.DistributionType]autocodesign.AppCodesignAssets{}
+	for distribution, assets := range assetsByDistributionType {
+		merged[distribution] = assets
+	}
+
+	for distribution, additionalAssets := range additional {
+		assets, ok := merged[distribution]
+		if !ok {
+			merged[distribution] = additionalAssets
+			continue
+		}
+
+		assets.ArchivableTargetProfilesByBundleID = mergeProfiles(assets.ArchivableTargetProfilesByBundleID, additionalAssets.ArchivableTargetProfilesByBundleID)
+		assets.UITestTargetProfilesByBundleID = mergeProfiles(assets.UITestTargetProfilesByBundleID, additionalAssets.UITestTargetProfilesByBundleID)
+		merged[distribution] = assets
+	}
+
+	return merged
+}
+
+func mergeProfiles(profilesByBundleID, additional map[string]autocodesign.Profile) map[string]autocodesign.Profile {
+	if len(additional) == 0 {
+		return profilesByBundleID
+	}
+
+	merged := map[string]autocodesign.Profile{}
+	for bundleID, profile := range profilesByBundleID {
+		merged[bundleID] = profile
+	}
+	for bundleID, profile := range additional {
+		merged[bundleID] = profile
+	}
+	return merged
+}
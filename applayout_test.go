@@ -0,0 +1,145 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/bitrise-io/go-xcode/v2/autocodesign"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/devportalclient/appstoreconnect"
+	"github.com/bitrise-io/go-xcode/xcodeproject/serialized"
+	"github.com/bitrise-io/go-xcode/xcodeproject/xcodeproj"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_targetPlatform(t *testing.T) {
+	configurationList := func(sdk string) xcodeproj.ConfigurationList {
+		settings := serialized.Object{}
+		if sdk != "" {
+			settings["SDKROOT"] = sdk
+		}
+		return xcodeproj.ConfigurationList{BuildConfigurations: []xcodeproj.BuildConfiguration{
+			{Name: "Release", BuildSettings: settings},
+		}}
+	}
+	xcProj := xcodeproj.XcodeProj{Proj: xcodeproj.Proj{BuildConfigurationList: configurationList("iphoneos")}}
+
+	tests := []struct {
+		name          string
+		target        xcodeproj.Target
+		configuration string
+		want          autocodesign.Platform
+	}{
+		{name: "target SDK", target: xcodeproj.Target{BuildConfigurationList: configurationList("watchos")}, configuration: "Release", want: WatchOS},
+		{name: "project SDK", target: xcodeproj.Target{BuildConfigurationList: configurationList("")}, configuration: "Release", want: autocodesign.IOS},
+		{name: "unknown SDK", target: xcodeproj.Target{BuildConfigurationList: configurationList("xros")}, configuration: "Release", want: ""},
+		{name: "unknown configuration", target: xcodeproj.Target{BuildConfigurationList: configurationList("watchos")}, configuration: "Debug", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, targetPlatform(xcProj, tt.target, tt.configuration))
+		})
+	}
+}
+
+func TestTargetAppLayout_SplitWatchAppLayout(t *testing.T) {
+	entitlements := map[string]autocodesign.Entitlements{
+		"io.bitrise.app":                  nil,
+		"io.bitrise.app.watchkitapp":      nil,
+		"io.bitrise.app.watchkitapp.comp": nil,
+	}
+
+	t.Run("companion watch app", func(t *testing.T) {
+		appLayout := TargetAppLayout{
+			AppLayout: autocodesign.AppLayout{
+				Platform:                               autocodesign.IOS,
+				EntitlementsByArchivableTargetBundleID: entitlements,
+				UITestTargetBundleIDs:                  []string{"io.bitrise.app.uitests"},
+			},
+			PlatformByBundleID: map[string]autocodesign.Platform{
+				"io.bitrise.app":                  autocodesign.IOS,
+				"io.bitrise.app.watchkitapp":      WatchOS,
+				"io.bitrise.app.watchkitapp.comp": WatchOS,
+			},
+		}
+
+		mainAppLayout, watchAppLayout := appLayout.SplitWatchAppLayout()
+		assert.Equal(t, &autocodesign.AppLayout{
+			Platform:                               autocodesign.IOS,
+			EntitlementsByArchivableTargetBundleID: map[string]autocodesign.Entitlements{"io.bitrise.app": nil},
+			UITestTargetBundleIDs:                  []string{"io.bitrise.app.uitests"},
+		}, mainAppLayout)
+		assert.Equal(t, &autocodesign.AppLayout{
+			Platform: autocodesign.IOS,
+			EntitlementsByArchivableTargetBundleID: map[string]autocodesign.Entitlements{
+				"io.bitrise.app.watchkitapp":      nil,
+				"io.bitrise.app.watchkitapp.comp": nil,
+			},
+		}, watchAppLayout)
+	})
+
+	t.Run("watch-only app", func(t *testing.T) {
+		appLayout := TargetAppLayout{
+			AppLayout: autocodesign.AppLayout{
+				Platform:                               WatchOS,
+				EntitlementsByArchivableTargetBundleID: entitlements,
+			},
+			PlatformByBundleID: map[string]autocodesign.Platform{
+				"io.bitrise.app":                  WatchOS,
+				"io.bitrise.app.watchkitapp":      WatchOS,
+				"io.bitrise.app.watchkitapp.comp": WatchOS,
+			},
+		}
+
+		mainAppLayout, watchAppLayout := appLayout.SplitWatchAppLayout()
+		assert.Nil(t, mainAppLayout)
+		assert.Equal(t, &autocodesign.AppLayout{Platform: autocodesign.IOS, EntitlementsByArchivableTargetBundleID: entitlements}, watchAppLayout)
+	})
+
+	t.Run("no watch app", func(t *testing.T) {
+		appLayout := TargetAppLayout{
+			AppLayout:          autocodesign.AppLayout{Platform: autocodesign.TVOS, EntitlementsByArchivableTargetBundleID: map[string]autocodesign.Entitlements{"io.bitrise.app": nil}},
+			PlatformByBundleID: map[string]autocodesign.Platform{"io.bitrise.app": autocodesign.TVOS},
+		}
+
+		mainAppLayout, watchAppLayout := appLayout.SplitWatchAppLayout()
+		assert.Equal(t, &appLayout.AppLayout, mainAppLayout)
+		assert.Nil(t, watchAppLayout)
+	})
+}
+
+func Test_mergeCodesignAssets(t *testing.T) {
+	profile := func(uuid string) autocodesign.Profile {
+		return plannedProfile{attributes: appstoreconnect.ProfileAttributes{UUID: uuid}}
+	}
+
+	assets := map[autocodesign.DistributionType]autocodesign.AppCodesignAssets{
+		autocodesign.Development: {
+			ArchivableTargetProfilesByBundleID: map[string]autocodesign.Profile{"io.bitrise.app": profile("uuid-1")},
+			UITestTargetProfilesByBundleID:     map[string]autocodesign.Profile{"io.bitrise.app.uitests": profile("uuid-2")},
+		},
+	}
+	watchAssets := map[autocodesign.DistributionType]autocodesign.AppCodesignAssets{
+		autocodesign.Development: {
+			ArchivableTargetProfilesByBundleID: map[string]autocodesign.Profile{"io.bitrise.app.watchkitapp": profile("uuid-3")},
+		},
+		autocodesign.AdHoc: {
+			ArchivableTargetProfilesByBundleID: map[string]autocodesign.Profile{"io.bitrise.app.watchkitapp": profile("uuid-4")},
+		},
+	}
+
+	merged := mergeCodesignAssets(assets, watchAssets)
+	assert.Equal(t, map[autocodesign.DistributionType]autocodesign.AppCodesignAssets{
+		autocodesign.Development: {
+			ArchivableTargetProfilesByBundleID: map[string]autocodesign.Profile{
+				"io.bitrise.app":             profile("uuid-1"),
+				"io.bitrise.app.watchkitapp": profile("uuid-3"),
+			},
+			UITestTargetProfilesByBundleID: map[string]autocodesign.Profile{"io.bitrise.app.uitests": profile("uuid-2")},
+		},
+		autocodesign.AdHoc: {
+			ArchivableTargetProfilesByBundleID: map[string]autocodesign.Profile{"io.bitrise.app.watchkitapp": profile("uuid-4")},
+		},
+	}, merged)
+	assert.Equal(t, 1, len(assets[autocodesign.Development].ArchivableTargetProfilesByBundleID))
+
+	assert.Equal(t, watchAssets, mergeCodesignAssets(nil, watchAssets))
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-xcode/certificateutil"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/devportalclient/appstoreconnect"
+)
+
+// installedProfilesDirs returns the directories Xcode reads the provisioning profiles from,
+// Xcode 16 moved them from the MobileDevice directory to the Xcode user data.
+func installedProfilesDirs() []string {
+	home := os.Getenv("HOME")
+	return []string{
+		filepath.Join(home, "Library/MobileDevice/Provisioning Profiles"),
+		filepath.Join(home, "Library/Developer/Xcode/UserData/Provisioning Profiles"),
+	}
+}
+
+// keychainAssetWriter installs the certificates into the keychain and the profiles into the directories Xcode reads them from.
+// If outputDir is set, the profiles are written there too, also named by their bundle ID and distribution type.
+// The code signing assets are ensured in multiple passes (watchOS, Mac Catalyst), sharing the certificates,
+// every certificate is installed only once, as the keychain refuses importing an already installed item.
+type keychainAssetWriter struct {
+	keychain     Keychain
+	profilesDirs []string
+	outputDir    string
+	installed    map[string]bool
+}
+
+func newKeychainAssetWriter(keychain Keychain, profilesDirs []string, outputDir string) *keychainAssetWriter {
+	return &keychainAssetWriter{
+		keychain:     keychain,
+		profilesDirs: profilesDirs,
+		outputDir:    outputDir,
+		installed:    map[string]bool{},
+	}
+}
+
+// Write ...
+func (w *keychainAssetWriter) Write(codesignAssetsByDistributionType map[autocodesign.DistributionType]autocodesign.AppCodesignAssets) error {
+	i := 0
+	for distribution, codesignAssets := range codesignAssetsByDistributionType {
+		if err := w.InstallCertificate(codesignAssets.Certificate); err != nil {
+			return fmt.Errorf("failed to install certificate: %s", err)
+		}
+
+		log.Printf("profiles:")
+		for _, profiles := range []map[string]autocodesign.Profile{codesignAssets.ArchivableTargetProfilesByBundleID, codesignAssets.UITestTargetProfilesByBundleID} {
+			for bundleID, profile := range profiles {
+				log.Printf("- %s", profile.Attributes().Name)
+
+				if err := w.writeProfile(profile, bundleID, distribution); err != nil {
+					return fmt.Errorf("failed to write profile to file: %s", err)
+				}
+			}
+		}
+
+		if i < len(codesignAssetsByDistributionType)-1 {
+			fmt.Println()
+		}
+		i++
+	}
+
+	return nil
+}
+
+// InstallCertificate ...
+func (w *keychainAssetWriter) InstallCertificate(certificate certificateutil.CertificateInfoModel) error {
+	log.Printf("certificate: %s", certificate.CommonName)
+	if w.installed[certificate.SHA1Fingerprint] {
+		log.Printf("certificate already installed")
+		return nil
+	}
+
+	// Empty passphrase provided, as already parsed certificate + private key
+	if err := w.keychain.InstallCertificate(certificate, ""); err != nil {
+		return err
+	}
+	w.installed[certificate.SHA1Fingerprint] = true
+	return nil
+}
+
+func (w *keychainAssetWriter) writeProfile(profile autocodesign.Profile, bundleID string, distribution autocodesign.DistributionType) error {
+	for _, dir := range w.profilesDirs {
+		if err := writeProfile(profile, dir, profile.Attributes().UUID); err != nil {
+			return err
+		}
+	}
+
+	if w.outputDir == "" {
+		return nil
+	}
+	if err := writeProfile(profile, w.outputDir, profile.Attributes().UUID); err != nil {
+		return err
+	}
+	return writeProfile(profile, w.outputDir, readableProfileFileName(bundleID, distribution))
+}
+
+// readableProfileFileName returns the stable profile file name (without extension) of the bundle ID and distribution type.
+func readableProfileFileName(bundleID string, distribution autocodesign.DistributionType) string {
+	return fmt.Sprintf("%s_%s", bundleID, distribution)
+}
+
+// writeProfile writes the profile into the directory with the given name.
+// The file extension depends on the profile's platform: `IOS` => `.mobileprovision`, `MAC_OS` => `.provisionprofile`
+func writeProfile(profile autocodesign.Profile, dir, name string) error {
+	var ext string
+	switch profile.Attributes().Platform {
+	case appstoreconnect.IOS:
+		ext = ".mobileprovision"
+	case appstoreconnect.MacOS:
+		ext = ".provisionprofile"
+	default:
+		return fmt.Errorf("unsupported platform: (%s), supported platforms: %s, %s", profile.Attributes().Platform, appstoreconnect.IOS, appstoreconnect.MacOS)
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create directory (%s) for provisioning profiles: %s", dir, err)
+	}
+
+	pth := filepath.Join(dir, name+ext)
+	return ioutil.WriteFile(pth, profile.Attributes().ProfileContent, 0600)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitrise-io/go-xcode/v2/autocodesign"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/devportalclient/appstoreconnect"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_writeProfile(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "Provisioning Profiles")
+
+	iosProfile := plannedProfile{attributes: appstoreconnect.ProfileAttributes{UUID: "uuid-1", Platform: appstoreconnect.IOS, ProfileContent: []byte("ios")}}
+	assert.NoError(t, writeProfile(iosProfile, dir, "uuid-1"))
+	content, err := ioutil.ReadFile(filepath.Join(dir, "uuid-1.mobileprovision"))
+	assert.NoError(t, err)
+	assert.Equal(t, "ios", string(content))
+
+	macProfile := plannedProfile{attributes: appstoreconnect.ProfileAttributes{UUID: "uuid-2", Platform: appstoreconnect.MacOS, ProfileContent: []byte("mac")}}
+	assert.NoError(t, writeProfile(macProfile, dir, "uuid-2"))
+	content, err = ioutil.ReadFile(filepath.Join(dir, "uuid-2.provisionprofile"))
+	assert.NoError(t, err)
+	assert.Equal(t, "mac", string(content))
+
+	unknownProfile := plannedProfile{attributes: appstoreconnect.ProfileAttributes{UUID: "uuid-3", Platform: "UNKNOWN"}}
+	assert.Error(t, writeProfile(unknownProfile, dir, "uuid-3"))
+}
+
+func Test_keychainAssetWriter_writeProfile(t *testing.T) {
+	tmpDir := t.TempDir()
+	profilesDirs := []string{filepath.Join(tmpDir, "MobileDevice"), filepath.Join(tmpDir, "UserData")}
+	outputDir := filepath.Join(tmpDir, "output")
+	profile := plannedProfile{attributes: appstoreconnect.ProfileAttributes{UUID: "uuid-1", Platform: appstoreconnect.IOS, ProfileContent: []byte("ios")}}
+
+	w := newKeychainAssetWriter(newP12Keystore(filepath.Join(tmpDir, "identities"), ""), profilesDirs, outputDir)
+	assert.NoError(t, w.writeProfile(profile, "io.bitrise.app", autocodesign.AppStore))
+
+	for _, pth := range []string{
+		filepath.Join(tmpDir, "MobileDevice", "uuid-1.mobileprovision"),
+		filepath.Join(tmpDir, "UserData", "uuid-1.mobileprovision"),
+		filepath.Join(outputDir, "uuid-1.mobileprovision"),
+		filepath.Join(outputDir, "io.bitrise.app_app-store.mobileprovision"),
+	} {
+		content, err := ioutil.ReadFile(pth)
+		assert.NoError(t, err)
+		assert.Equal(t, "ios", string(content))
+	}
+}
+
+func Test_keychainAssetWriter_Write(t *testing.T) {
+	tmpDir := t.TempDir()
+	keystoreDir := filepath.Join(tmpDir, "identities")
+	profilesDir := filepath.Join(tmpDir, "Provisioning Profiles")
+	identity := newTestIdentity(t, "Apple Distribution: Bitrise Bot")
+	profile := plannedProfile{attributes: appstoreconnect.ProfileAttributes{UUID: "uuid-1", Platform: appstoreconnect.IOS, ProfileContent: []byte("ios")}}
+
+	w := newKeychainAssetWriter(newP12Keystore(keystoreDir, "password"), []string{profilesDir}, "")
+	assets := map[autocodesign.DistributionType]autocodesign.AppCodesignAssets{
+		autocodesign.AppStore: {
+			Certificate:                        identity,
+			ArchivableTargetProfilesByBundleID: map[string]autocodesign.Profile{"io.bitrise.app": profile},
+		},
+	}
+	assert.NoError(t, w.Write(assets))
+	// The already installed certificate is skipped
+	assert.NoError(t, w.Write(assets))
+
+	pths, err := filepath.Glob(filepath.Join(keystoreDir, "*.p12"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(keystoreDir, identity.SHA1Fingerprint+".p12")}, pths)
+
+	content, err := ioutil.ReadFile(filepath.Join(profilesDir, "uuid-1.mobileprovision"))
+	assert.NoError(t, err)
+	assert.Equal(t, "ios", string(content))
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+const redacted = "[REDACTED]"
+
+// cassetteRedactedFields are the JSON fields scrubbed from the recorded bodies:
+// the certificate signing requests of the generated certificates.
+var cassetteRedactedFields = map[string]bool{
+	"csrContent": true,
+}
+
+// cassetteRedactedHeaders are the response headers not recorded.
+// The request headers are not recorded at all, as they carry the JWT.
+var cassetteRedactedHeaders = []string{"Set-Cookie"}
+
+// Cassette is a recording of the App Store Connect API traffic, replayed by the regression tests
+type Cassette struct {
+	Interactions []CassetteInteraction `json:"interactions"`
+}
+
+// CassetteInteraction is a recorded request and its response
+type CassetteInteraction struct {
+	Request  CassetteRequest  `json:"request"`
+	Response CassetteResponse `json:"response"`
+}
+
+// CassetteRequest ...
+type CassetteRequest struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	Body   string `json:"body,omitempty"`
+}
+
+// CassetteResponse ...
+type CassetteResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+}
+
+// cassetteRecorder is an http.RoundTripper, which records the sanitized requests and responses into a cassette file.
+// The cassette is written after every response, so that it is available even if the Step fails.
+type cassetteRecorder struct {
+	transport http.RoundTripper
+	pth       string
+
+	mu       sync.Mutex
+	cassette Cassette
+}
+
+// newCassetteRecordingClient wraps the HTTP client to record its traffic into the cassette file.
+// It returns an *http.Client, as the App Store Connect API client authenticates only the requests of those.
+func newCassetteRecordingClient(client *http.Client, pth string) *http.Client {
+	transport := client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	return &http.Client{
+		Transport:     &cassetteRecorder{transport: transport, pth: pth},
+		CheckRedirect: client.CheckRedirect,
+		Jar:           client.Jar,
+		Timeout:       client.Timeout,
+	}
+}
+
+// RoundTrip ...
+func (r *cassetteRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var requestBody []byte
+	if req.Body != nil {
+		var err error
+		if requestBody, err = ioutil.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		if err := req.Body.Close(); err != nil {
+			return nil, err
+		}
+
+		req = req.Clone(req.Context())
+		req.Body = ioutil.NopCloser(bytes.NewReader(requestBody))
+	}
+
+	resp, err := r.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	responseBody, err := ioutil.ReadAll(resp.Body)
+	if cerr := resp.Body.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(responseBody))
+
+	header := resp.Header.Clone()
+	for _, key := range cassetteRedactedHeaders {
+		header.Del(key)
+	}
+
+	r.record(CassetteInteraction{
+		Request: CassetteRequest{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Body:   sanitizeCassetteBody(requestBody),
+		},
+		Response: CassetteResponse{
+			StatusCode: resp.StatusCode,
+			Header:     header,
+			Body:       sanitizeCassetteBody(responseBody),
+		},
+	})
+
+	return resp, nil
+}
+
+func (r *cassetteRecorder) record(interaction CassetteInteraction) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+	if err := r.cassette.WriteToFile(r.pth); err != nil {
+		log.Warnf("Failed to write App Store Connect API cassette: %s", err)
+	}
+}
+
+// WriteToFile ...
+func (c Cassette) WriteToFile(pth string) error {
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal cassette: %s", err)
+	}
+
+	return ioutil.WriteFile(pth, b, 0600)
+}
+
+// sanitizeCassetteBody scrubs the redacted fields of a JSON body, other bodies are recorded as they are.
+func sanitizeCassetteBody(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+
+	var v interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&v); err != nil {
+		return string(body)
+	}
+
+	b, err := json.Marshal(redactJSON(v))
+	if err != nil {
+		return string(body)
+	}
+	return string(b)
+}
+
+func redactJSON(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, field := range value {
+			if cassetteRedactedFields[key] {
+				value[key] = redacted
+			} else {
+				value[key] = redactJSON(field)
+			}
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = redactJSON(item)
+		}
+	}
+	return v
+}
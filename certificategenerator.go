@@ -0,0 +1,139 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/bitrise-io/go-steputils/v2/stepconf"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-xcode/certificateutil"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/devportalclient/appstoreconnect"
+)
+
+// CertificateGenerator creates certificates on the Developer Portal, from a locally generated private key.
+type CertificateGenerator struct {
+	service ProvisioningService
+}
+
+// NewCertificateGenerator ...
+func NewCertificateGenerator(service ProvisioningService) CertificateGenerator {
+	return CertificateGenerator{service: service}
+}
+
+// Generate creates a private key and a certificate signing request,
+// and returns the certificate signed by Apple together with the private key.
+func (g CertificateGenerator) Generate(certificateType appstoreconnect.CertificateType) (autocodesign.Certificate, error) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return autocodesign.Certificate{}, fmt.Errorf("failed to generate private key: %s", err)
+	}
+
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:            pkix.Name{CommonName: "Bitrise"},
+		SignatureAlgorithm: x509.SHA256WithRSA,
+	}, privateKey)
+	if err != nil {
+		return autocodesign.Certificate{}, fmt.Errorf("failed to create certificate signing request: %s", err)
+	}
+	csrPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr})
+
+	r, err := g.service.CreateCertificate(CertificateCreateRequest{
+		Data: CertificateCreateRequestData{
+			Attributes: CertificateCreateRequestDataAttributes{
+				CertificateType: certificateType,
+				CsrContent:      string(csrPEM),
+			},
+			Type: "certificates",
+		},
+	})
+	if err != nil {
+		return autocodesign.Certificate{}, fmt.Errorf("failed to create %s certificate: %s", certificateType, err)
+	}
+
+	cert, err := x509.ParseCertificate(r.Data.Attributes.CertificateContent)
+	if err != nil {
+		return autocodesign.Certificate{}, fmt.Errorf("failed to parse certificate: %s", err)
+	}
+
+	return autocodesign.Certificate{
+		CertificateInfo: certificateutil.NewCertificateInfo(*cert, privateKey),
+		ID:              r.Data.ID,
+	}, nil
+}
+
+// generatingCertificateProvider creates a certificate on the Developer Portal,
+// if none of the provided certificates has the required type.
+type generatingCertificateProvider struct {
+	autocodesign.CertificateProvider
+
+	generator       CertificateGenerator
+	certificateType appstoreconnect.CertificateType
+	passphrase      stepconf.Secret
+	p12Pth          string
+
+	generated *certificateutil.CertificateInfoModel
+}
+
+func newGeneratingCertificateProvider(provider autocodesign.CertificateProvider, generator CertificateGenerator, certificateType appstoreconnect.CertificateType, passphrase stepconf.Secret, p12Pth string) *generatingCertificateProvider {
+	return &generatingCertificateProvider{
+		CertificateProvider: provider,
+		generator:           generator,
+		certificateType:     certificateType,
+		passphrase:          passphrase,
+		p12Pth:              p12Pth,
+	}
+}
+
+// GetCertificates ...
+func (p *generatingCertificateProvider) GetCertificates() ([]certificateutil.CertificateInfoModel, error) {
+	certs, err := p.CertificateProvider.GetCertificates()
+	if err != nil {
+		return nil, err
+	}
+
+	certsByType, err := localCertificatesByType(certs)
+	if err != nil {
+		return nil, err
+	}
+	if len(certsByType[p.certificateType]) > 0 {
+		return certs, nil
+	}
+	if p.generated != nil {
+		return append(certs, *p.generated), nil
+	}
+
+	fmt.Println()
+	log.Warnf("No valid %s type certificate provided, creating one", p.certificateType)
+
+	generated, err := p.generator.Generate(p.certificateType)
+	if err != nil {
+		return nil, err
+	}
+	cert := generated.CertificateInfo
+	log.Donef("Certificate created: %s", cert)
+
+	if err := writeCertificate(cert, p.passphrase, p.p12Pth); err != nil {
+		return nil, err
+	}
+	p.generated = &cert
+
+	return append(certs, cert), nil
+}
+
+// writeCertificate exports the certificate with its private key as a p12 file.
+func writeCertificate(cert certificateutil.CertificateInfoModel, passphrase stepconf.Secret, pth string) error {
+	b, err := cert.EncodeToP12(string(passphrase))
+	if err != nil {
+		return fmt.Errorf("failed to encode certificate: %s", err)
+	}
+	if err := ioutil.WriteFile(pth, b, 0600); err != nil {
+		return fmt.Errorf("failed to write certificate: %s", err)
+	}
+	return nil
+}
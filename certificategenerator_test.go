@@ -0,0 +1,92 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/devportalclient/appstoreconnect"
+	"github.com/stretchr/testify/assert"
+)
+
+// unauthenticatedHTTPClient makes appstoreconnect.Client skip JWT signing.
+type unauthenticatedHTTPClient struct {
+	client *http.Client
+}
+
+func (c unauthenticatedHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	return c.client.Do(req)
+}
+
+func newTestAPIClient(t *testing.T, server *httptest.Server) *appstoreconnect.Client {
+	client := appstoreconnect.NewClient(unauthenticatedHTTPClient{client: server.Client()}, "KEYID", "ISSUER", nil)
+	baseURL, err := url.Parse(server.URL + "/")
+	assert.NoError(t, err)
+	client.BaseURL = baseURL
+	return client
+}
+
+func TestCertificateGenerator_Generate(t *testing.T) {
+	caKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/v1/certificates", r.URL.Path)
+
+		var body CertificateCreateRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, appstoreconnect.IOSDevelopment, body.Data.Attributes.CertificateType)
+
+		block, _ := pem.Decode([]byte(body.Data.Attributes.CsrContent))
+		if !assert.NotNil(t, block) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		csr, err := x509.ParseCertificateRequest(block.Bytes)
+		assert.NoError(t, err)
+		assert.NoError(t, csr.CheckSignature())
+
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: "Apple Development: Bitrise Bot (ABCD1234)", OrganizationalUnit: []string{"TEAM"}},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(365 * 24 * time.Hour),
+		}
+		content, err := x509.CreateCertificate(rand.Reader, template, template, csr.PublicKey, caKey)
+		assert.NoError(t, err)
+
+		w.WriteHeader(http.StatusCreated)
+		assert.NoError(t, json.NewEncoder(w).Encode(CertificateResponse{Data: appstoreconnect.Certificate{
+			Attributes: appstoreconnect.CertificateAttributes{CertificateContent: content},
+			ID:         "cert-id",
+		}}))
+	}))
+	defer server.Close()
+
+	generator := NewCertificateGenerator(NewProvisioningService(newTestAPIClient(t, server)))
+	generated, err := generator.Generate(appstoreconnect.IOSDevelopment)
+	assert.NoError(t, err)
+	assert.Equal(t, "cert-id", generated.ID)
+
+	cert := generated.CertificateInfo
+	assert.Equal(t, "Apple Development: Bitrise Bot (ABCD1234)", cert.CommonName)
+	assert.Equal(t, "TEAM", cert.TeamID)
+
+	privateKey, ok := cert.PrivateKey.(*rsa.PrivateKey)
+	if assert.True(t, ok) {
+		assert.Equal(t, privateKey.Public(), cert.Certificate.PublicKey)
+	}
+
+	_, err = cert.EncodeToP12("passphrase")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"crypto/x509"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-steputils/v2/stepconf"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-xcode/certificateutil"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/devportalclient/appstoreconnect"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/devportalclient/appstoreconnectclient"
+)
+
+const (
+	plannedCertificateID = "planned-certificate"
+
+	temporaryProfileNameSuffix = " (rotating)"
+)
+
+// PortalCertificate is a certificate registered on the Developer Portal
+type PortalCertificate struct {
+	ID              string
+	Type            appstoreconnect.CertificateType
+	PortalSerial    string
+	CertificateInfo certificateutil.CertificateInfoModel
+}
+
+func (c PortalCertificate) matchesSerial(serial string) bool {
+	return strings.EqualFold(c.PortalSerial, serial) || c.CertificateInfo.Serial == serial
+}
+
+func (c PortalCertificate) String() string {
+	return fmt.Sprintf("%s [%s] (ID: %s, serial: %s, expiry: %s)", c.CertificateInfo.CommonName, c.Type, c.ID, c.PortalSerial, c.CertificateInfo.EndDate)
+}
+
+// CertificateManager lists, revokes and rotates the certificates of the team.
+// If plan is not nil, the Developer Portal changes are recorded into it instead of being executed.
+type CertificateManager struct {
+	client    *appstoreconnect.Client
+	service   ProvisioningService
+	generator CertificateGenerator
+	profiles  BitriseProfileMatcher
+	plan      *PortalPlan
+}
+
+// NewCertificateManager ...
+func NewCertificateManager(client *appstoreconnect.Client, profiles BitriseProfileMatcher, plan *PortalPlan) CertificateManager {
+	service := NewProvisioningService(client)
+	return CertificateManager{
+		client:    client,
+		service:   service,
+		generator: NewCertificateGenerator(service),
+		profiles:  profiles,
+		plan:      plan,
+	}
+}
+
+// ListCertificates returns the certificates of the given type, sorted by expiry.
+// All certificates are returned if certificateType is empty.
+func (m CertificateManager) ListCertificates(certificateType appstoreconnect.CertificateType) ([]PortalCertificate, error) {
+	pager := NewPager(m.client, "certificates", PagerOptions{
+		Filter: map[string]string{"certificateType": string(certificateType)},
+	})
+	var certificates []PortalCertificate
+	for pager.HasNext() {
+		var response appstoreconnect.CertificatesResponse
+		if err := pager.Next(&response); err != nil {
+			return nil, fmt.Errorf("failed to list certificates: %s", err)
+		}
+
+		for _, certificate := range response.Data {
+			cert, err := x509.ParseCertificate(certificate.Attributes.CertificateContent)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse certificate (%s): %s", certificate.ID, err)
+			}
+
+			certificates = append(certificates, PortalCertificate{
+				ID:              certificate.ID,
+				Type:            certificate.Attributes.CertificateType,
+				PortalSerial:    certificate.Attributes.SerialNumber,
+				CertificateInfo: certificateutil.NewCertificateInfo(*cert, nil),
+			})
+		}
+	}
+
+	sort.SliceStable(certificates, func(i, j int) bool {
+		return certificates[i].CertificateInfo.EndDate.Before(certificates[j].CertificateInfo.EndDate)
+	})
+
+	return certificates, nil
+}
+
+// Revoke revokes the certificates with the given serial numbers.
+// Both the Developer Portal's (hexadecimal) and the decimal form of the serial number is accepted.
+func (m CertificateManager) Revoke(serials []string) error {
+	certificates, err := m.ListCertificates("")
+	if err != nil {
+		return err
+	}
+
+	var toRevoke []PortalCertificate
+	for _, serial := range serials {
+		found := false
+		for _, certificate := range certificates {
+			if certificate.matchesSerial(serial) {
+				toRevoke = append(toRevoke, certificate)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("no certificate found with serial %s", serial)
+		}
+	}
+
+	for _, certificate := range toRevoke {
+		if err := m.revoke(certificate); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rotatedCertificateTypes are the certificate types used by the distribution types:
+// the legacy iOS types and the Apple Development / Apple Distribution types of the current certificates,
+// and the Developer ID Application type.
+var rotatedCertificateTypes = map[autocodesign.DistributionType][]appstoreconnect.CertificateType{
+	autocodesign.Development: {appstoreconnect.IOSDevelopment, appstoreconnect.Development},
+	autocodesign.AppStore:    {appstoreconnect.IOSDistribution, appstoreconnect.Distribution},
+	autocodesign.AdHoc:       {appstoreconnect.IOSDistribution, appstoreconnect.Distribution},
+	autocodesign.Enterprise:  {appstoreconnect.IOSDistribution, appstoreconnect.Distribution},
+	DeveloperID:              {appstoreconnect.DeveloperIDApplication},
+}
+
+// Rotate replaces the certificates of the distribution type expiring within minDaysValid days:
+// it creates a new certificate and writes it with its private key to p12Pth, regenerates the Bitrise managed
+// provisioning profiles referencing an expiring certificate, then revokes the expiring certificates.
+// The certificate is written before touching the profiles, so that it is not lost if a later step fails.
+// The returned certificate is nil if no rotation was needed, or if running in dry-run mode,
+// it is returned together with the error if regenerating the profiles or revoking the old certificates fails.
+func (m CertificateManager) Rotate(distribution autocodesign.DistributionType, minDaysValid int, passphrase stepconf.Secret, p12Pth string) (*certificateutil.CertificateInfoModel, error) {
+	certificateType := certificateTypes[distribution]
+
+	var certificates []PortalCertificate
+	for _, rotatedType := range rotatedCertificateTypes[distribution] {
+		typeCertificates, err := m.ListCertificates(rotatedType)
+		if err != nil {
+			return nil, err
+		}
+		certificates = append(certificates, typeCertificates...)
+	}
+
+	deadline := time.Now().AddDate(0, 0, minDaysValid)
+	var expiring []PortalCertificate
+	for _, certificate := range certificates {
+		if certificate.CertificateInfo.EndDate.Before(deadline) {
+			expiring = append(expiring, certificate)
+		}
+	}
+
+	if len(expiring) == 0 {
+		log.Donef("No %s certificate expires in %d days", distribution, minDaysValid)
+		return nil, nil
+	}
+
+	log.Warnf("%d %s certificate(s) expire in %d days:", len(expiring), distribution, minDaysValid)
+	for _, certificate := range expiring {
+		log.Printf("- %s", certificate)
+	}
+
+	fmt.Println()
+	log.Infof("Creating %s certificate", certificateType)
+	var newCertificate *certificateutil.CertificateInfoModel
+	newCertificateID := plannedCertificateID
+	if m.plan != nil {
+		m.plan.record(PortalChange{Action: CreateCertificateAction, CertificateType: certificateType})
+	} else {
+		generated, err := m.generator.Generate(certificateType)
+		if err != nil {
+			return nil, err
+		}
+		log.Donef("Certificate created: %s", generated.CertificateInfo)
+
+		if err := writeCertificate(generated.CertificateInfo, passphrase, p12Pth); err != nil {
+			return nil, err
+		}
+
+		newCertificate = &generated.CertificateInfo
+		newCertificateID = generated.ID
+	}
+
+	fmt.Println()
+	log.Infof("Regenerating provisioning profiles")
+	if err := m.regenerateProfiles(expiring, newCertificateID); err != nil {
+		return newCertificate, err
+	}
+
+	fmt.Println()
+	log.Infof("Revoking expiring certificates")
+	for _, certificate := range expiring {
+		if err := m.revoke(certificate); err != nil {
+			return newCertificate, err
+		}
+	}
+
+	return newCertificate, nil
+}
+
+func (m CertificateManager) revoke(certificate PortalCertificate) error {
+	if m.plan != nil {
+		m.plan.record(PortalChange{
+			Action:          RevokeCertificateAction,
+			ID:              certificate.ID,
+			Name:            certificate.CertificateInfo.CommonName,
+			CertificateType: certificate.Type,
+			Serial:          certificate.PortalSerial,
+		})
+		return nil
+	}
+
+	if err := m.service.RevokeCertificate(certificate.ID); err != nil {
+		return fmt.Errorf("failed to revoke certificate %s: %s", certificate, err)
+	}
+	log.Donef("Certificate revoked: %s", certificate)
+
+	return nil
+}
+
+// regenerateProfiles recreates the Bitrise managed profiles referencing any of the old certificates,
+// with the same name, type, bundle ID and devices, but with the new certificate.
+func (m CertificateManager) regenerateProfiles(oldCertificates []PortalCertificate, newCertificateID string) error {
+	oldCertificateIDs := map[string]bool{}
+	for _, certificate := range oldCertificates {
+		oldCertificateIDs[certificate.ID] = true
+	}
+
+	profiles, err := m.profiles.ListProfiles()
+	if err != nil {
+		return err
+	}
+
+	regenerated := 0
+	for i := range profiles {
+		profile := appstoreconnectclient.NewAPIProfile(m.client, &profiles[i].Profile)
+		name := profile.Attributes().Name
+
+		certificateIDs, err := profile.CertificateIDs()
+		if err != nil {
+			return fmt.Errorf("failed to list certificates of profile %s: %s", name, err)
+		}
+
+		newCertificateIDs := []string{newCertificateID}
+		referencesOld := false
+		for _, id := range certificateIDs {
+			if oldCertificateIDs[id] {
+				referencesOld = true
+			} else if id != newCertificateID {
+				newCertificateIDs = append(newCertificateIDs, id)
+			}
+		}
+		if !referencesOld {
+			continue
+		}
+
+		bundleID, err := profile.BundleID()
+		if err != nil {
+			return fmt.Errorf("failed to get bundle ID of profile %s: %s", name, err)
+		}
+		deviceIDs, err := profile.DeviceIDs()
+		if err != nil {
+			return fmt.Errorf("failed to list devices of profile %s: %s", name, err)
+		}
+
+		log.Printf("Regenerating profile: %s", name)
+		if err := m.replaceProfile(profile.ID(), name, profile.Attributes().ProfileType, bundleID, newCertificateIDs, deviceIDs); err != nil {
+			return err
+		}
+		regenerated++
+	}
+
+	log.Donef("%d profile(s) regenerated", regenerated)
+
+	return nil
+}
+
+// replaceProfile replaces the profile with a new one of the same name, type, bundle ID and devices.
+// The Developer Portal rejects duplicate profile names, so the replacement is first created with a temporary name,
+// and the old profile is only deleted once it exists: the bundle ID is never left without a valid profile.
+func (m CertificateManager) replaceProfile(id, name string, profileType appstoreconnect.ProfileType, bundleID appstoreconnect.BundleID, certificateIDs, deviceIDs []string) error {
+	if m.plan != nil {
+		m.plan.record(PortalChange{
+			Action:         CreateProfileAction,
+			Name:           name,
+			BundleID:       bundleID.Attributes.Identifier,
+			ProfileType:    profileType,
+			CertificateIDs: certificateIDs,
+			DeviceIDs:      deviceIDs,
+		})
+		m.plan.record(PortalChange{Action: DeleteProfileAction, ID: id, Name: name})
+		return nil
+	}
+
+	temporaryName := name + temporaryProfileNameSuffix
+	temporary, err := m.client.Provisioning.CreateProfile(appstoreconnect.NewProfileCreateRequest(profileType, temporaryName, bundleID.ID, certificateIDs, deviceIDs))
+	if err != nil {
+		return fmt.Errorf("failed to create profile %s: %s", temporaryName, err)
+	}
+	if err := m.client.Provisioning.DeleteProfile(id); err != nil {
+		return fmt.Errorf("failed to delete profile %s: %s", name, err)
+	}
+	if _, err := m.client.Provisioning.CreateProfile(appstoreconnect.NewProfileCreateRequest(profileType, name, bundleID.ID, certificateIDs, deviceIDs)); err != nil {
+		return fmt.Errorf("failed to create profile %s, %s is kept in its place: %s", name, temporaryName, err)
+	}
+	if err := m.client.Provisioning.DeleteProfile(temporary.Data.ID); err != nil {
+		return fmt.Errorf("failed to delete profile %s: %s", temporaryName, err)
+	}
+	return nil
+}
+
+func printCertificates(certificates []PortalCertificate) {
+	fmt.Println()
+	if len(certificates) == 0 {
+		log.Warnf("No certificates found")
+		return
+	}
+
+	log.Infof("Certificates (%d):", len(certificates))
+	for _, certificate := range certificates {
+		if err := certificate.CertificateInfo.CheckValidity(); err != nil {
+			log.Warnf("- %s: %s", certificate, err)
+			continue
+		}
+		log.Printf("- %s", certificate)
+	}
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/go-xcode/certificateutil"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/devportalclient/appstoreconnect"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestCertificateContent(t *testing.T, commonName string, serial int64, notAfter time.Time) []byte {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    notAfter.AddDate(-1, 0, 0),
+		NotAfter:     notAfter,
+	}
+	content, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+
+	return content
+}
+
+func TestCertificateManager_Revoke(t *testing.T) {
+	expiry := time.Now().AddDate(0, 1, 0)
+	certificates := appstoreconnect.CertificatesResponse{Data: []appstoreconnect.Certificate{
+		{
+			ID:   "cert-1",
+			Type: "certificates",
+			Attributes: appstoreconnect.CertificateAttributes{
+				CertificateContent: newTestCertificateContent(t, "Apple Distribution: Bitrise Bot", 255, expiry),
+				CertificateType:    appstoreconnect.IOSDistribution,
+				SerialNumber:       "FF",
+			},
+		},
+		{
+			ID:   "cert-2",
+			Type: "certificates",
+			Attributes: appstoreconnect.CertificateAttributes{
+				CertificateContent: newTestCertificateContent(t, "Apple Development: Bitrise Bot", 16, expiry.AddDate(0, 1, 0)),
+				CertificateType:    appstoreconnect.IOSDevelopment,
+				SerialNumber:       "10",
+			},
+		},
+	}}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		assert.Equal(t, "/v1/certificates", r.URL.Path)
+		assert.NoError(t, json.NewEncoder(w).Encode(certificates))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		serials []string
+		want    []PortalChange
+		wantErr string
+	}{
+		{
+			name:    "portal and decimal serials",
+			serials: []string{"ff", "16"},
+			want: []PortalChange{
+				{Action: RevokeCertificateAction, ID: "cert-1", Name: "Apple Distribution: Bitrise Bot", CertificateType: appstoreconnect.IOSDistribution, Serial: "FF"},
+				{Action: RevokeCertificateAction, ID: "cert-2", Name: "Apple Development: Bitrise Bot", CertificateType: appstoreconnect.IOSDevelopment, Serial: "10"},
+			},
+		},
+		{
+			name:    "unknown serial",
+			serials: []string{"FF", "ABC"},
+			wantErr: "no certificate found with serial ABC",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := &PortalPlan{}
+			apiClient := newTestAPIClient(t, server)
+			manager := NewCertificateManager(apiClient, NewBitriseProfileMatcher(apiClient, nil, "", ""), plan)
+
+			err := manager.Revoke(tt.serials)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				assert.Empty(t, plan.Changes)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, plan.Changes)
+		})
+	}
+}
+
+func TestCertificateManager_Rotate(t *testing.T) {
+	caKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	// An Apple Distribution certificate has the DISTRIBUTION type, not IOS_DISTRIBUTION
+	expiring := appstoreconnect.Certificate{
+		ID:   "cert-1",
+		Type: "certificates",
+		Attributes: appstoreconnect.CertificateAttributes{
+			CertificateContent: newTestCertificateContent(t, "Apple Distribution: Bitrise Bot", 255, time.Now().AddDate(0, 0, 10)),
+			CertificateType:    appstoreconnect.Distribution,
+			SerialNumber:       "FF",
+		},
+	}
+
+	failProfiles := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/certificates":
+			var certificates appstoreconnect.CertificatesResponse
+			if r.URL.Query().Get("filter[certificateType]") == string(appstoreconnect.Distribution) {
+				certificates.Data = append(certificates.Data, expiring)
+			}
+			assert.NoError(t, json.NewEncoder(w).Encode(certificates))
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/certificates":
+			var body CertificateCreateRequest
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.Equal(t, appstoreconnect.IOSDistribution, body.Data.Attributes.CertificateType)
+
+			block, _ := pem.Decode([]byte(body.Data.Attributes.CsrContent))
+			csr, err := x509.ParseCertificateRequest(block.Bytes)
+			assert.NoError(t, err)
+			template := &x509.Certificate{
+				SerialNumber: big.NewInt(2),
+				Subject:      pkix.Name{CommonName: "iPhone Distribution: Bitrise Bot (ABCD1234)"},
+				NotBefore:    time.Now().Add(-time.Hour),
+				NotAfter:     time.Now().AddDate(1, 0, 0),
+			}
+			content, err := x509.CreateCertificate(rand.Reader, template, template, csr.PublicKey, caKey)
+			assert.NoError(t, err)
+
+			w.WriteHeader(http.StatusCreated)
+			assert.NoError(t, json.NewEncoder(w).Encode(CertificateResponse{Data: appstoreconnect.Certificate{
+				Attributes: appstoreconnect.CertificateAttributes{CertificateContent: content},
+				ID:         "cert-2",
+			}}))
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/profiles" && failProfiles:
+			w.WriteHeader(http.StatusInternalServerError)
+			_, err := w.Write([]byte(`{"errors":[{"status":"500","code":"UNEXPECTED_ERROR","title":"An unexpected error occurred."}]}`))
+			assert.NoError(t, err)
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/profiles":
+			assert.NoError(t, json.NewEncoder(w).Encode(appstoreconnect.ProfilesResponse{}))
+		default:
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	t.Run("rotates the Apple Distribution certificate", func(t *testing.T) {
+		plan := &PortalPlan{}
+		apiClient := newTestAPIClient(t, server)
+		manager := NewCertificateManager(apiClient, NewBitriseProfileMatcher(apiClient, nil, "", ""), plan)
+
+		certificate, err := manager.Rotate(autocodesign.AppStore, 30, "", "")
+		assert.NoError(t, err)
+		assert.Nil(t, certificate)
+		assert.Equal(t, []PortalChange{
+			{Action: CreateCertificateAction, CertificateType: appstoreconnect.IOSDistribution},
+			{Action: RevokeCertificateAction, ID: "cert-1", Name: "Apple Distribution: Bitrise Bot", CertificateType: appstoreconnect.Distribution, Serial: "FF"},
+		}, plan.Changes)
+	})
+
+	t.Run("keeps the new certificate if regenerating the profiles fails", func(t *testing.T) {
+		failProfiles = true
+		p12Pth := filepath.Join(t.TempDir(), "generated_certificate.p12")
+		apiClient := newTestAPIClient(t, server)
+		manager := NewCertificateManager(apiClient, NewBitriseProfileMatcher(apiClient, nil, "", ""), nil)
+
+		certificate, err := manager.Rotate(autocodesign.AppStore, 30, "passphrase", p12Pth)
+		assert.Error(t, err)
+		if assert.NotNil(t, certificate) {
+			assert.Equal(t, "iPhone Distribution: Bitrise Bot (ABCD1234)", certificate.CommonName)
+		}
+
+		certs, err := certificateutil.CertificatesFromPKCS12File(p12Pth, "passphrase")
+		assert.NoError(t, err)
+		if assert.Len(t, certs, 1) {
+			assert.NotNil(t, certs[0].PrivateKey)
+		}
+	})
+}
+
+func TestCertificateManager_regenerateProfiles(t *testing.T) {
+	setup := func(t *testing.T) (*fakeAppStoreConnect, string, string, string) {
+		server := newFakeAppStoreConnect(t)
+		oldCertificateID := server.AddCertificate(appstoreconnect.IOSDevelopment, newTestIdentity(t, "Apple Development: Bitrise Bot"))
+		newCertificateID := server.AddCertificate(appstoreconnect.IOSDevelopment, newTestIdentity(t, "Apple Development: Bitrise Bot"))
+		bundleIDID := server.AddBundleID("io.bitrise.app")
+		profileID := server.AddProfile(fakeProfile{
+			Name:           "Bitrise iOS development - (io.bitrise.app)",
+			Type:           appstoreconnect.IOSAppDevelopment,
+			BundleIDID:     bundleIDID,
+			CertificateIDs: []string{oldCertificateID},
+			Expiry:         time.Now().AddDate(1, 0, 0),
+		})
+		return server, oldCertificateID, newCertificateID, profileID
+	}
+	mutations := func(server *fakeAppStoreConnect) []string {
+		var requests []string
+		for _, request := range server.Requests() {
+			if !strings.HasPrefix(request, http.MethodGet) {
+				requests = append(requests, request)
+			}
+		}
+		return requests
+	}
+
+	t.Run("replaces the profile, keeping its name", func(t *testing.T) {
+		server, oldCertificateID, newCertificateID, profileID := setup(t)
+		apiClient := newFakeAPIClient(t, server)
+		manager := NewCertificateManager(apiClient, NewBitriseProfileMatcher(apiClient, nil, "", ""), nil)
+
+		assert.NoError(t, manager.regenerateProfiles([]PortalCertificate{{ID: oldCertificateID}}, newCertificateID))
+		if profiles := server.Profiles(); assert.Len(t, profiles, 1) {
+			assert.Equal(t, "Bitrise iOS development - (io.bitrise.app)", profiles[0].Name)
+			assert.Equal(t, []string{newCertificateID}, profiles[0].CertificateIDs)
+		}
+		requests := mutations(server)
+		if assert.Len(t, requests, 4) {
+			assert.Equal(t, "POST /v1/profiles", requests[0])
+			assert.Equal(t, "DELETE /v1/profiles/"+profileID, requests[1])
+			assert.Equal(t, "POST /v1/profiles", requests[2])
+		}
+	})
+
+	t.Run("replaces the profiles named by the template", func(t *testing.T) {
+		identity := newTestIdentity(t, "Apple Development: Bitrise Bot")
+		template, err := NewProfileNameTemplate(`CI {{.Platform}} {{.Distribution}} {{.BundleID}}`)
+		assert.NoError(t, err)
+
+		server := newFakeAppStoreConnect(t)
+		oldCertificateID := server.AddCertificate(appstoreconnect.IOSDevelopment, identity)
+		client := newAppIDNamingDevPortalClient(newProfileNamingDevPortalClient(newFakeDevPortalClient(t, server, ""), template, "", ""))
+		_, err = ensureFakeCodesignAssets(client, identity)
+		assert.NoError(t, err)
+		newCertificateID := server.AddCertificate(appstoreconnect.IOSDevelopment, newTestIdentity(t, "Apple Development: Bitrise Bot"))
+
+		apiClient := newFakeAPIClient(t, server)
+		manager := NewCertificateManager(apiClient, NewBitriseProfileMatcher(apiClient, &template, "", ""), nil)
+
+		assert.NoError(t, manager.regenerateProfiles([]PortalCertificate{{ID: oldCertificateID}}, newCertificateID))
+		if profiles := server.Profiles(); assert.Len(t, profiles, 1) {
+			assert.Equal(t, "CI iOS development io.bitrise.app", profiles[0].Name)
+			assert.Equal(t, []string{newCertificateID}, profiles[0].CertificateIDs)
+		}
+	})
+
+	t.Run("keeps the old profile if the replacement can not be created", func(t *testing.T) {
+		server, oldCertificateID, newCertificateID, profileID := setup(t)
+		server.FailNext(http.MethodPost, "/v1/profiles", http.StatusConflict, "ENTITY_ERROR", "The profile can not be created.")
+		apiClient := newFakeAPIClient(t, server)
+		manager := NewCertificateManager(apiClient, NewBitriseProfileMatcher(apiClient, nil, "", ""), nil)
+
+		assert.Error(t, manager.regenerateProfiles([]PortalCertificate{{ID: oldCertificateID}}, newCertificateID))
+		if profiles := server.Profiles(); assert.Len(t, profiles, 1) {
+			assert.Equal(t, profileID, profiles[0].ID)
+		}
+		assert.NotContains(t, mutations(server), "DELETE /v1/profiles/"+profileID)
+	})
+}
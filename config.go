@@ -24,21 +24,65 @@ type Config struct {
 	Configuration       string `env:"configuration"`
 	SignUITestTargets   bool   `env:"sign_uitest_targets,opt[yes,no]"`
 	RegisterTestDevices bool   `env:"register_test_devices,opt[yes,no]"`
+	TestDevicesFile     string `env:"test_devices_file"`
+	DeviceBudget        int    `env:"device_budget"`
 
-	Distribution        string `env:"distribution_type,opt[development,app-store,ad-hoc,enterprise]"`
-	MinProfileDaysValid int    `env:"min_profile_days_valid"`
+	ProfileDeviceUDIDs       string `env:"profile_device_udids"`
+	ProfileDeviceNamePattern string `env:"profile_device_name_pattern"`
+	ProfileDeviceClasses     string `env:"profile_device_classes"`
+	ProfileTestDevicesOnly   bool   `env:"profile_test_devices_only,opt[yes,no]"`
 
-	CertificateURLList        string          `env:"certificate_urls,required"`
-	CertificatePassphraseList stepconf.Secret `env:"passphrases"`
-	KeychainPath              string          `env:"keychain_path,required"`
-	KeychainPassword          stepconf.Secret `env:"keychain_password,required"`
+	MacCatalyst                 bool `env:"mac_catalyst,opt[yes,no]"`
+	MacCatalystDerivedBundleIDs bool `env:"mac_catalyst_derived_bundle_ids,opt[yes,no]"`
 
-	VerboseLog bool `env:"verbose_log,opt[no,yes]"`
+	Distribution        string `env:"distribution_type,opt[development,app-store,ad-hoc,enterprise,developer-id]"`
+	MinProfileDaysValid int    `env:"min_profile_days_valid"`
+	DryRun              bool   `env:"dry_run,opt[yes,no]"`
+	ProfileNameTemplate string `env:"profile_name_template"`
+	ProfileNameSuffix   string `env:"profile_name_suffix"`
+	AdoptProfiles       bool   `env:"adopt_existing_profiles,opt[yes,no]"`
+
+	Mode                     string `env:"mode,opt[ensure,list-certificates,revoke-certificates,rotate-certificates,prune,manage-devices,offline,cleanup-keychain]"`
+	RevokeCertificateSerials string `env:"revoke_certificate_serials"`
+	CertificateRotationDays  int    `env:"certificate_rotation_days"`
+	LiveBundleIDs            string `env:"live_bundle_ids"`
+	ConfirmPrune             bool   `env:"confirm_prune,opt[yes,no]"`
+	KeepDeviceUDIDs          string `env:"keep_device_udids"`
+
+	CertificateURLList             string          `env:"certificate_urls"`
+	CertificatePassphraseList      stepconf.Secret `env:"passphrases"`
+	ProvisioningProfileURLs        stepconf.Secret `env:"provisioning_profile_urls"`
+	ProfilesOutputDir              string          `env:"profiles_output_dir"`
+	GenerateCertificate            bool            `env:"generate_certificate,opt[yes,no]"`
+	GeneratedCertificatePassphrase stepconf.Secret `env:"generated_certificate_passphrase"`
+	KeychainPath                   string          `env:"keychain_path,required"`
+	KeychainPassword               stepconf.Secret `env:"keychain_password,required"`
+	KeychainBackend                string          `env:"keychain_backend,opt[keychain,temporary-keychain,p12-dir]"`
+	KeystoreDir                    string          `env:"keystore_dir"`
+
+	OutputDir  string `env:"output_dir,required"`
+	VerboseLog bool   `env:"verbose_log,opt[no,yes]"`
+	Timeout    int    `env:"timeout"`
 
 	BuildAPIToken string `env:"build_api_token"`
 	BuildURL      string `env:"build_url"`
+
+	APIBaseURL        string `env:"api_base_url"`
+	RecordAPICassette bool   `env:"record_api_cassette,opt[yes,no]"`
 }
 
+// Modes ...
+const (
+	EnsureMode             = "ensure"
+	ListCertificatesMode   = "list-certificates"
+	RevokeCertificatesMode = "revoke-certificates"
+	RotateCertificatesMode = "rotate-certificates"
+	PruneMode              = "prune"
+	ManageDevicesMode      = "manage-devices"
+	OfflineMode            = "offline"
+	CleanupKeychainMode    = "cleanup-keychain"
+)
+
 // DistributionType ...
 func (c Config) DistributionType() autocodesign.DistributionType {
 	return autocodesign.DistributionType(c.Distribution)
@@ -47,6 +91,9 @@ func (c Config) DistributionType() autocodesign.DistributionType {
 // ValidateCertificates validates if the number of certificate URLs matches those of passphrases
 func (c Config) ValidateCertificates() ([]string, []string, error) {
 	pfxURLs := splitAndClean(c.CertificateURLList, "|", true)
+	if len(pfxURLs) == 0 && strings.TrimSpace(string(c.CertificatePassphraseList)) == "" {
+		return nil, nil, nil
+	}
 	passphrases := splitAndClean(string(c.CertificatePassphraseList), "|", false)
 
 	if len(pfxURLs) != len(passphrases) {
@@ -26,6 +26,13 @@ func TestConfig_ValidateCertificates(t *testing.T) {
 			want1:   []string{"pass1", ""},
 			wantErr: "",
 		},
+		{
+			name:    "no certificates",
+			config:  Config{CertificateURLList: "", CertificatePassphraseList: ""},
+			want:    nil,
+			want1:   nil,
+			wantErr: "",
+		},
 		{
 			name:    "",
 			config:  Config{CertificateURLList: "url1|url2", CertificatePassphraseList: "pass1"},
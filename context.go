@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+
+	"github.com/bitrise-io/go-xcode/devportalservice"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/devportalclient/appstoreconnect"
+)
+
+// contextError describes why the context is done, the Step's timeout or a cancellation.
+func contextError(ctx context.Context) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return errors.New("timeout exceeded")
+	}
+	return ctx.Err()
+}
+
+// contextTransport is an http.RoundTripper, which sends the requests created without a context
+// (like the ones of appstoreconnect.ProvisioningService) with the Step's context.
+type contextTransport struct {
+	ctx       context.Context
+	transport http.RoundTripper
+}
+
+// newContextHTTPClient wraps the HTTP client to bound its requests with the context.
+// It returns an *http.Client, as the App Store Connect API client authenticates only the requests of those.
+func newContextHTTPClient(ctx context.Context, client *http.Client) *http.Client {
+	transport := client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	return &http.Client{
+		Transport:     contextTransport{ctx: ctx, transport: transport},
+		CheckRedirect: client.CheckRedirect,
+		Jar:           client.Jar,
+		Timeout:       client.Timeout,
+	}
+}
+
+// RoundTrip ...
+func (t contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Context() == context.Background() {
+		req = req.WithContext(t.ctx)
+	}
+	return t.transport.RoundTrip(req)
+}
+
+// contextDevPortalClient fails the Developer Portal calls once the context is done.
+// The errors returned after that do not wrap the underlying error: a 404 (Not Found) response
+// is an autocodesign.ProfilesInconsistentError, which would make autocodesign retry ensuring the profile.
+type contextDevPortalClient struct {
+	autocodesign.DevPortalClient
+
+	ctx context.Context
+}
+
+func newContextDevPortalClient(ctx context.Context, client autocodesign.DevPortalClient) contextDevPortalClient {
+	return contextDevPortalClient{
+		DevPortalClient: client,
+		ctx:             ctx,
+	}
+}
+
+// QueryCertificateBySerial ...
+func (c contextDevPortalClient) QueryCertificateBySerial(serial big.Int) (autocodesign.Certificate, error) {
+	if c.ctx.Err() != nil {
+		return autocodesign.Certificate{}, contextError(c.ctx)
+	}
+	cert, err := c.DevPortalClient.QueryCertificateBySerial(serial)
+	return cert, c.wrapErr(err)
+}
+
+// QueryAllIOSCertificates ...
+func (c contextDevPortalClient) QueryAllIOSCertificates() (map[appstoreconnect.CertificateType][]autocodesign.Certificate, error) {
+	if c.ctx.Err() != nil {
+		return nil, contextError(c.ctx)
+	}
+	certs, err := c.DevPortalClient.QueryAllIOSCertificates()
+	return certs, c.wrapErr(err)
+}
+
+// ListDevices ...
+func (c contextDevPortalClient) ListDevices(udid string, platform appstoreconnect.DevicePlatform) ([]appstoreconnect.Device, error) {
+	if c.ctx.Err() != nil {
+		return nil, contextError(c.ctx)
+	}
+	devices, err := c.DevPortalClient.ListDevices(udid, platform)
+	return devices, c.wrapErr(err)
+}
+
+// RegisterDevice ...
+func (c contextDevPortalClient) RegisterDevice(testDevice devportalservice.TestDevice) (*appstoreconnect.Device, error) {
+	if c.ctx.Err() != nil {
+		return nil, contextError(c.ctx)
+	}
+	device, err := c.DevPortalClient.RegisterDevice(testDevice)
+	return device, c.wrapErr(err)
+}
+
+// FindProfile ...
+func (c contextDevPortalClient) FindProfile(name string, profileType appstoreconnect.ProfileType) (autocodesign.Profile, error) {
+	if c.ctx.Err() != nil {
+		return nil, contextError(c.ctx)
+	}
+	profile, err := c.DevPortalClient.FindProfile(name, profileType)
+	if err != nil || profile == nil {
+		return nil, c.wrapErr(err)
+	}
+	return contextProfile{Profile: profile, ctx: c.ctx}, nil
+}
+
+// DeleteProfile ...
+func (c contextDevPortalClient) DeleteProfile(id string) error {
+	if c.ctx.Err() != nil {
+		return contextError(c.ctx)
+	}
+	return c.wrapErr(c.DevPortalClient.DeleteProfile(id))
+}
+
+// CreateProfile ...
+func (c contextDevPortalClient) CreateProfile(name string, profileType appstoreconnect.ProfileType, bundleID appstoreconnect.BundleID, certificateIDs []string, deviceIDs []string) (autocodesign.Profile, error) {
+	if c.ctx.Err() != nil {
+		return nil, contextError(c.ctx)
+	}
+	profile, err := c.DevPortalClient.CreateProfile(name, profileType, bundleID, certificateIDs, deviceIDs)
+	if err != nil || profile == nil {
+		return nil, c.wrapErr(err)
+	}
+	return contextProfile{Profile: profile, ctx: c.ctx}, nil
+}
+
+// FindBundleID ...
+func (c contextDevPortalClient) FindBundleID(bundleIDIdentifier string) (*appstoreconnect.BundleID, error) {
+	if c.ctx.Err() != nil {
+		return nil, contextError(c.ctx)
+	}
+	bundleID, err := c.DevPortalClient.FindBundleID(bundleIDIdentifier)
+	return bundleID, c.wrapErr(err)
+}
+
+// CheckBundleIDEntitlements ...
+func (c contextDevPortalClient) CheckBundleIDEntitlements(bundleID appstoreconnect.BundleID, appEntitlements autocodesign.Entitlements) error {
+	if c.ctx.Err() != nil {
+		return contextError(c.ctx)
+	}
+	return c.wrapErr(c.DevPortalClient.CheckBundleIDEntitlements(bundleID, appEntitlements))
+}
+
+// SyncBundleID ...
+func (c contextDevPortalClient) SyncBundleID(bundleID appstoreconnect.BundleID, appEntitlements autocodesign.Entitlements) error {
+	if c.ctx.Err() != nil {
+		return contextError(c.ctx)
+	}
+	return c.wrapErr(c.DevPortalClient.SyncBundleID(bundleID, appEntitlements))
+}
+
+// CreateBundleID ...
+func (c contextDevPortalClient) CreateBundleID(bundleIDIdentifier, appIDName string) (*appstoreconnect.BundleID, error) {
+	if c.ctx.Err() != nil {
+		return nil, contextError(c.ctx)
+	}
+	bundleID, err := c.DevPortalClient.CreateBundleID(bundleIDIdentifier, appIDName)
+	return bundleID, c.wrapErr(err)
+}
+
+func (c contextDevPortalClient) wrapErr(err error) error {
+	return wrapContextErr(c.ctx, err)
+}
+
+// contextProfile fails the profile's Developer Portal calls (certificates, devices and bundle ID relationships) once the context is done.
+type contextProfile struct {
+	autocodesign.Profile
+
+	ctx context.Context
+}
+
+// CertificateIDs ...
+func (p contextProfile) CertificateIDs() ([]string, error) {
+	if p.ctx.Err() != nil {
+		return nil, contextError(p.ctx)
+	}
+	ids, err := p.Profile.CertificateIDs()
+	return ids, wrapContextErr(p.ctx, err)
+}
+
+// DeviceIDs ...
+func (p contextProfile) DeviceIDs() ([]string, error) {
+	if p.ctx.Err() != nil {
+		return nil, contextError(p.ctx)
+	}
+	ids, err := p.Profile.DeviceIDs()
+	return ids, wrapContextErr(p.ctx, err)
+}
+
+// BundleID ...
+func (p contextProfile) BundleID() (appstoreconnect.BundleID, error) {
+	if p.ctx.Err() != nil {
+		return appstoreconnect.BundleID{}, contextError(p.ctx)
+	}
+	bundleID, err := p.Profile.BundleID()
+	return bundleID, wrapContextErr(p.ctx, err)
+}
+
+// wrapContextErr replaces the error with the context's error, if the context is done.
+// The underlying error is kept only in the message, as autocodesign reports some of the failed requests
+// as missing assets (for example, a certificate not found on the Developer Portal).
+func wrapContextErr(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() == nil {
+		return err
+	}
+	return fmt.Errorf("%s: %s", contextError(ctx), err)
+}
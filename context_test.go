@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/go-xcode/appleauth"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/devportalclient/appstoreconnect"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextDevPortalClient_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	inner := new(autocodesign.MockDevPortalClient)
+	client := newContextDevPortalClient(ctx, inner)
+
+	_, err := client.FindProfile(testProfileName, appstoreconnect.IOSAppDevelopment)
+	assert.Equal(t, context.Canceled, err)
+	assert.Empty(t, inner.Calls)
+}
+
+// TestContextDevPortalClient_StopsProfileRetry checks that a 404 (Not Found) response of a request aborted by the timeout
+// is not an autocodesign.ProfilesInconsistentError, which would make autocodesign retry ensuring the profile.
+func TestContextDevPortalClient_StopsProfileRetry(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	expired, expire := context.WithDeadline(ctx, time.Now())
+	defer expire()
+	<-expired.Done()
+
+	inconsistentErr := autocodesign.NewProfilesInconsistentError(errors.New("profile not found"))
+
+	inner := new(autocodesign.MockDevPortalClient)
+	inner.On("DeleteProfile", "profile-1").Return(inconsistentErr)
+
+	err := newContextDevPortalClient(ctx, inner).DeleteProfile("profile-1")
+	assert.True(t, errors.As(err, &autocodesign.ProfilesInconsistentError{}), "the error is kept while the context is not done")
+
+	err = wrapContextErr(expired, inconsistentErr)
+	assert.False(t, errors.As(err, &autocodesign.ProfilesInconsistentError{}))
+	assert.EqualError(t, err, "timeout exceeded: provisioning profiles were concurrently changed on Developer Portal, profile not found")
+}
+
+func TestNewContextHTTPClient_Timeout(t *testing.T) {
+	done := make(chan bool)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer server.Close()
+	defer close(done)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	client := newContextHTTPClient(ctx, &http.Client{})
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	assert.NoError(t, err)
+
+	start := time.Now()
+	_, err = client.Do(req)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "unexpected error: %v", err)
+	assert.Less(t, int64(time.Since(start)), int64(5*time.Second))
+}
+
+func TestCreateClient_Canceled(t *testing.T) {
+	identity := newTestIdentity(t, "Apple Development: Bitrise Bot")
+	server := newFakeAppStoreConnect(t)
+	server.AddCertificate(appstoreconnect.IOSDevelopment, identity)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client, _, err := createClient(
+		ctx,
+		[]appleauth.Source{&appleauth.InputAPIKeySource{}},
+		appleauth.Inputs{APIIssuer: "fake-issuer", APIKeyPath: "file://" + writeFakeAPIKey(t)},
+		"", nil, apiClientOptions{BaseURL: server.URL},
+	)
+	assert.NoError(t, err)
+	cancel()
+
+	_, err = ensureFakeCodesignAssets(newContextDevPortalClient(ctx, client), identity)
+	if assert.Error(t, err) {
+		assert.Contains(t, wrapContextErr(ctx, err).Error(), "context canceled: failed to get valid certificates")
+	}
+	assert.Empty(t, server.Requests())
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-xcode/devportalservice"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/devportalclient/appstoreconnect"
+)
+
+// DeviceFilter selects the devices included in the development and ad-hoc provisioning profiles.
+// A device is included if it matches every set criteria.
+type DeviceFilter struct {
+	UDIDs           []string
+	NamePattern     *regexp.Regexp
+	Classes         []appstoreconnect.DeviceClass
+	TestDevicesOnly bool
+	TestDevices     []devportalservice.TestDevice
+}
+
+// NewDeviceFilter ...
+func NewDeviceFilter(udids []string, namePattern string, classes []string, testDevicesOnly bool, testDevices []devportalservice.TestDevice) (DeviceFilter, error) {
+	filter := DeviceFilter{
+		UDIDs:           udids,
+		TestDevicesOnly: testDevicesOnly,
+		TestDevices:     testDevices,
+	}
+
+	if namePattern != "" {
+		pattern, err := regexp.Compile(namePattern)
+		if err != nil {
+			return DeviceFilter{}, fmt.Errorf("invalid device name pattern (%s): %s", namePattern, err)
+		}
+		filter.NamePattern = pattern
+	}
+
+	for _, class := range classes {
+		deviceClass, err := parseDeviceClass(class)
+		if err != nil {
+			return DeviceFilter{}, err
+		}
+		filter.Classes = append(filter.Classes, deviceClass)
+	}
+
+	return filter, nil
+}
+
+// IsEmpty returns true if the filter includes every device.
+func (f DeviceFilter) IsEmpty() bool {
+	return len(f.UDIDs) == 0 && f.NamePattern == nil && len(f.Classes) == 0 && !f.TestDevicesOnly
+}
+
+// Match ...
+func (f DeviceFilter) Match(device appstoreconnect.Device) bool {
+	if len(f.UDIDs) > 0 && !containsUDID(f.UDIDs, device.Attributes.UDID) {
+		return false
+	}
+	if f.NamePattern != nil && !f.NamePattern.MatchString(device.Attributes.Name) {
+		return false
+	}
+	if len(f.Classes) > 0 && !containsDeviceClass(f.Classes, device.Attributes.DeviceClass) {
+		return false
+	}
+	if f.TestDevicesOnly {
+		var testUDIDs []string
+		for _, testDevice := range f.TestDevices {
+			testUDIDs = append(testUDIDs, testDevice.DeviceID)
+		}
+		if !containsUDID(testUDIDs, device.Attributes.UDID) {
+			return false
+		}
+	}
+	return true
+}
+
+func parseDeviceClass(class string) (appstoreconnect.DeviceClass, error) {
+	for _, deviceClass := range deviceClasses {
+		if strings.EqualFold(string(deviceClass), class) {
+			return deviceClass, nil
+		}
+	}
+	return "", fmt.Errorf("invalid device class (%s), available: %v", class, deviceClasses)
+}
+
+func containsDeviceClass(classes []appstoreconnect.DeviceClass, class appstoreconnect.DeviceClass) bool {
+	for _, c := range classes {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+func containsUDID(udids []string, udid string) bool {
+	for _, u := range udids {
+		if devportalservice.IsEqualUDID(u, udid) {
+			return true
+		}
+	}
+	return false
+}
+
+// deviceFilteringDevPortalClient hides the devices not matching the filter from autocodesign,
+// so that they are not added to the development and ad-hoc provisioning profiles.
+type deviceFilteringDevPortalClient struct {
+	autocodesign.DevPortalClient
+
+	filter DeviceFilter
+	listed []appstoreconnect.Device
+}
+
+func newDeviceFilteringDevPortalClient(devPortalClient autocodesign.DevPortalClient, filter DeviceFilter) *deviceFilteringDevPortalClient {
+	return &deviceFilteringDevPortalClient{
+		DevPortalClient: devPortalClient,
+		filter:          filter,
+	}
+}
+
+// ListDevices ...
+func (c *deviceFilteringDevPortalClient) ListDevices(udid string, platform appstoreconnect.DevicePlatform) ([]appstoreconnect.Device, error) {
+	devices, err := c.DevPortalClient.ListDevices(udid, platform)
+	if err != nil {
+		return nil, err
+	}
+	c.listed = append(c.listed, devices...)
+
+	var filtered []appstoreconnect.Device
+	for _, device := range devices {
+		if c.filter.Match(device) {
+			filtered = append(filtered, device)
+		}
+	}
+	log.Printf("%d of the %d devices match the profile device filter", len(filtered), len(devices))
+
+	return filtered, nil
+}
+
+// RegisterDevice ...
+func (c *deviceFilteringDevPortalClient) RegisterDevice(testDevice devportalservice.TestDevice) (*appstoreconnect.Device, error) {
+	for _, device := range c.listed {
+		if devportalservice.IsEqualUDID(device.Attributes.UDID, testDevice.DeviceID) {
+			log.Printf("device already registered, but does not match the profile device filter")
+			return nil, nil
+		}
+	}
+
+	device, err := c.DevPortalClient.RegisterDevice(testDevice)
+	if err != nil || device == nil {
+		return device, err
+	}
+	if !c.filter.Match(*device) {
+		log.Printf("device registered, but does not match the profile device filter")
+		return nil, nil
+	}
+
+	return device, nil
+}
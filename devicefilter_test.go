@@ -0,0 +1,79 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/bitrise-io/go-xcode/devportalservice"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/devportalclient/appstoreconnect"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeviceFilter_Match(t *testing.T) {
+	iphone := newTestDevice("1", appstoreconnect.Iphone, appstoreconnect.Enabled)
+	ipad := newTestDevice("2", appstoreconnect.Ipad, appstoreconnect.Enabled)
+	ipad.Attributes.Name = "QA iPad"
+
+	tests := []struct {
+		name            string
+		udids           []string
+		namePattern     string
+		classes         []string
+		testDevicesOnly bool
+		want            []appstoreconnect.Device
+	}{
+		{name: "no filter", want: []appstoreconnect.Device{iphone, ipad}},
+		{name: "UDIDs", udids: []string{"UDID-1"}, want: []appstoreconnect.Device{iphone}},
+		{name: "name pattern", namePattern: "^QA ", want: []appstoreconnect.Device{ipad}},
+		{name: "device classes", classes: []string{"iphone", "IPOD"}, want: []appstoreconnect.Device{iphone}},
+		{name: "test devices only", testDevicesOnly: true, want: []appstoreconnect.Device{ipad}},
+		{name: "all criteria have to match", udids: []string{"udid-1"}, namePattern: "^QA ", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := NewDeviceFilter(tt.udids, tt.namePattern, tt.classes, tt.testDevicesOnly, []devportalservice.TestDevice{{DeviceID: "udid-2"}})
+			assert.NoError(t, err)
+
+			var got []appstoreconnect.Device
+			for _, device := range []appstoreconnect.Device{iphone, ipad} {
+				if filter.Match(device) {
+					got = append(got, device)
+				}
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err := NewDeviceFilter(nil, "", []string{"PHONE"}, false, nil)
+	assert.Error(t, err)
+}
+
+func TestDeviceFilteringDevPortalClient(t *testing.T) {
+	iphone := newTestDevice("1", appstoreconnect.Iphone, appstoreconnect.Enabled)
+	ipad := newTestDevice("2", appstoreconnect.Ipad, appstoreconnect.Enabled)
+	newIpad := newTestDevice("3", appstoreconnect.Ipad, appstoreconnect.Enabled)
+
+	mockClient := new(autocodesign.MockDevPortalClient)
+	mockClient.On("ListDevices", "", appstoreconnect.IOSDevice).Return([]appstoreconnect.Device{iphone, ipad}, nil)
+	mockClient.On("RegisterDevice", devportalservice.TestDevice{DeviceID: "udid-3"}).Return(&newIpad, nil).Once()
+
+	filter, err := NewDeviceFilter(nil, "", []string{"IPHONE"}, false, nil)
+	assert.NoError(t, err)
+	client := newDeviceFilteringDevPortalClient(mockClient, filter)
+
+	devices, err := client.ListDevices("", appstoreconnect.IOSDevice)
+	assert.NoError(t, err)
+	assert.Equal(t, []appstoreconnect.Device{iphone}, devices)
+
+	// already registered, but filtered out: not registered again
+	device, err := client.RegisterDevice(devportalservice.TestDevice{DeviceID: "UDID-2"})
+	assert.NoError(t, err)
+	assert.Nil(t, device)
+
+	// registered, but filtered out
+	device, err = client.RegisterDevice(devportalservice.TestDevice{DeviceID: "udid-3"})
+	assert.NoError(t, err)
+	assert.Nil(t, device)
+
+	mockClient.AssertExpectations(t)
+}
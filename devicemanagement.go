@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-xcode/devportalservice"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/devportalclient/appstoreconnect"
+)
+
+// appleDeviceLimit is the number of devices per device family which can be registered in a membership year
+const appleDeviceLimit = 100
+
+var deviceClasses = []appstoreconnect.DeviceClass{
+	appstoreconnect.Iphone,
+	appstoreconnect.Ipad,
+	appstoreconnect.Ipod,
+	appstoreconnect.AppleWatch,
+	appstoreconnect.AppleTV,
+	appstoreconnect.Mac,
+}
+
+// DeviceSlots are the enabled device counts per device class.
+type DeviceSlots struct {
+	Enabled map[appstoreconnect.DeviceClass]int
+	Budget  int
+}
+
+// NewDeviceSlots counts the enabled devices per device class, the disabled devices are not counted against the budget.
+func NewDeviceSlots(devices []appstoreconnect.Device, budget int) DeviceSlots {
+	enabled := map[appstoreconnect.DeviceClass]int{}
+	for _, device := range devices {
+		if device.Attributes.Status == appstoreconnect.Enabled {
+			enabled[device.Attributes.DeviceClass]++
+		}
+	}
+	return DeviceSlots{Enabled: enabled, Budget: budget}
+}
+
+// Remaining returns the number of devices of the given class which can be registered within the budget.
+func (s DeviceSlots) Remaining(class appstoreconnect.DeviceClass) int {
+	if remaining := s.Budget - s.Enabled[class]; remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// DeviceManager reports the device slot usage and disables the retired devices.
+// If plan is not nil, the Developer Portal changes are recorded into it instead of being executed.
+type DeviceManager struct {
+	client  *appstoreconnect.Client
+	service ProvisioningService
+	plan    *PortalPlan
+}
+
+// NewDeviceManager ...
+func NewDeviceManager(client *appstoreconnect.Client, plan *PortalPlan) DeviceManager {
+	return DeviceManager{
+		client:  client,
+		service: NewProvisioningService(client),
+		plan:    plan,
+	}
+}
+
+// ListDevices lists the registered devices of every platform and status.
+func (m DeviceManager) ListDevices() ([]appstoreconnect.Device, error) {
+	return listAllDevices(m.client)
+}
+
+// DisableDevices disables the enabled devices not in the keep-list.
+func (m DeviceManager) DisableDevices(devices []appstoreconnect.Device, keepUDIDs []string) error {
+	for _, device := range retiredDevices(devices, keepUDIDs) {
+		if m.plan != nil {
+			m.plan.record(PortalChange{Action: DisableDeviceAction, ID: device.ID, Name: device.Attributes.Name, UDID: device.Attributes.UDID})
+			continue
+		}
+
+		if _, err := m.service.UpdateDevice(device.ID, NewDeviceUpdateRequest(device.ID, DeviceUpdateRequestDataAttributes{Status: appstoreconnect.Disabled})); err != nil {
+			return fmt.Errorf("failed to disable device %s (%s): %s", device.Attributes.Name, device.Attributes.UDID, err)
+		}
+		log.Donef("Device disabled: %s (UDID: %s)", device.Attributes.Name, device.Attributes.UDID)
+	}
+	return nil
+}
+
+func retiredDevices(devices []appstoreconnect.Device, keepUDIDs []string) []appstoreconnect.Device {
+	var retired []appstoreconnect.Device
+	for _, device := range devices {
+		if device.Attributes.Status != appstoreconnect.Enabled {
+			continue
+		}
+
+		if !containsUDID(keepUDIDs, device.Attributes.UDID) {
+			retired = append(retired, device)
+		}
+	}
+	return retired
+}
+
+func listAllDevices(client *appstoreconnect.Client) ([]appstoreconnect.Device, error) {
+	pager := NewPager(client, "devices", PagerOptions{})
+	var devices []appstoreconnect.Device
+	for pager.HasNext() {
+		var response appstoreconnect.DevicesResponse
+		if err := pager.Next(&response); err != nil {
+			return nil, fmt.Errorf("failed to list devices: %s", err)
+		}
+
+		devices = append(devices, response.Data...)
+	}
+	return devices, nil
+}
+
+// testDeviceClasses returns the device classes the test device may belong to,
+// the device type of a Bitrise connection test device does not tell apart iPhones, iPads and iPods.
+func testDeviceClasses(testDevice devportalservice.TestDevice) []appstoreconnect.DeviceClass {
+	switch strings.ToLower(strings.ReplaceAll(testDevice.DeviceType, "_", "")) {
+	case "iphone":
+		return []appstoreconnect.DeviceClass{appstoreconnect.Iphone}
+	case "ipad":
+		return []appstoreconnect.DeviceClass{appstoreconnect.Ipad}
+	case "ipod":
+		return []appstoreconnect.DeviceClass{appstoreconnect.Ipod}
+	case "watch", "watchos", "applewatch":
+		return []appstoreconnect.DeviceClass{appstoreconnect.AppleWatch}
+	case "tvos", "appletv":
+		return []appstoreconnect.DeviceClass{appstoreconnect.AppleTV}
+	case "mac", "macos", "osx":
+		return []appstoreconnect.DeviceClass{appstoreconnect.Mac}
+	default:
+		return []appstoreconnect.DeviceClass{appstoreconnect.Iphone, appstoreconnect.Ipad, appstoreconnect.Ipod}
+	}
+}
+
+// deviceBudgetDevPortalClient refuses registering a test device if the budget of (any of) its device class is used up by the enabled devices.
+type deviceBudgetDevPortalClient struct {
+	autocodesign.DevPortalClient
+
+	listDevices func() ([]appstoreconnect.Device, error)
+	budget      int
+	slots       *DeviceSlots
+}
+
+func newDeviceBudgetDevPortalClient(devPortalClient autocodesign.DevPortalClient, client *appstoreconnect.Client, budget int) *deviceBudgetDevPortalClient {
+	return &deviceBudgetDevPortalClient{
+		DevPortalClient: devPortalClient,
+		listDevices: func() ([]appstoreconnect.Device, error) {
+			return listAllDevices(client)
+		},
+		budget: budget,
+	}
+}
+
+// RegisterDevice ...
+func (c *deviceBudgetDevPortalClient) RegisterDevice(testDevice devportalservice.TestDevice) (*appstoreconnect.Device, error) {
+	if c.slots == nil {
+		devices, err := c.listDevices()
+		if err != nil {
+			return nil, err
+		}
+
+		slots := NewDeviceSlots(devices, c.budget)
+		c.slots = &slots
+		printDeviceSlots(slots)
+	}
+
+	classes := testDeviceClasses(testDevice)
+	for _, class := range classes {
+		if c.slots.Remaining(class) == 0 {
+			return nil, appstoreconnect.DeviceRegistrationError{
+				Reason: fmt.Sprintf("device budget used up: %d/%d %s devices enabled", c.slots.Enabled[class], c.budget, class),
+			}
+		}
+	}
+
+	device, err := c.DevPortalClient.RegisterDevice(testDevice)
+	if err != nil {
+		return nil, err
+	}
+
+	// The registered device's class is not known in dry-run, count it against every possible class
+	if device != nil && device.Attributes.DeviceClass != "" {
+		classes = []appstoreconnect.DeviceClass{device.Attributes.DeviceClass}
+	}
+	for _, class := range classes {
+		c.slots.Enabled[class]++
+	}
+
+	return device, nil
+}
+
+func printDeviceSlots(slots DeviceSlots) {
+	log.Printf("Enabled devices (budget: %d per device class):", slots.Budget)
+	for _, class := range deviceClasses {
+		log.Printf("- %s: %d enabled, %d remaining", class, slots.Enabled[class], slots.Remaining(class))
+	}
+}
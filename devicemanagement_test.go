@@ -0,0 +1,93 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/bitrise-io/go-xcode/devportalservice"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/devportalclient/appstoreconnect"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestDevice(id string, class appstoreconnect.DeviceClass, status appstoreconnect.Status) appstoreconnect.Device {
+	return appstoreconnect.Device{
+		ID: id,
+		Attributes: appstoreconnect.DeviceAttributes{
+			Name:        "device " + id,
+			UDID:        "udid-" + id,
+			DeviceClass: class,
+			Status:      status,
+		},
+	}
+}
+
+func TestDeviceSlots(t *testing.T) {
+	slots := NewDeviceSlots([]appstoreconnect.Device{
+		newTestDevice("1", appstoreconnect.Iphone, appstoreconnect.Enabled),
+		newTestDevice("2", appstoreconnect.Iphone, appstoreconnect.Disabled),
+		newTestDevice("3", appstoreconnect.Ipad, appstoreconnect.Enabled),
+		newTestDevice("4", appstoreconnect.Ipad, appstoreconnect.Enabled),
+		newTestDevice("5", appstoreconnect.Ipad, appstoreconnect.Enabled),
+	}, 2)
+
+	// the disabled iPhone is not counted
+	assert.Equal(t, 1, slots.Enabled[appstoreconnect.Iphone])
+	assert.Equal(t, 1, slots.Remaining(appstoreconnect.Iphone))
+	assert.Equal(t, 0, slots.Remaining(appstoreconnect.Ipad))
+	assert.Equal(t, 2, slots.Remaining(appstoreconnect.Mac))
+}
+
+func TestDeviceManager_DisableDevices(t *testing.T) {
+	plan := &PortalPlan{}
+	manager := DeviceManager{plan: plan}
+
+	err := manager.DisableDevices([]appstoreconnect.Device{
+		newTestDevice("1", appstoreconnect.Iphone, appstoreconnect.Enabled),
+		newTestDevice("2", appstoreconnect.Iphone, appstoreconnect.Disabled),
+		newTestDevice("3", appstoreconnect.Ipad, appstoreconnect.Enabled),
+	}, []string{"UDID-1"})
+	assert.NoError(t, err)
+	assert.Equal(t, []PortalChange{
+		{Action: DisableDeviceAction, ID: "3", Name: "device 3", UDID: "udid-3"},
+	}, plan.Changes)
+}
+
+func TestDeviceBudgetDevPortalClient_RegisterDevice(t *testing.T) {
+	watch := devportalservice.TestDevice{DeviceID: "watch-udid", DeviceType: "watch"}
+	iphone := devportalservice.TestDevice{DeviceID: "iphone-udid", DeviceType: "ios"}
+	mac := devportalservice.TestDevice{DeviceID: "mac-udid", DeviceType: "mac"}
+
+	registered := &appstoreconnect.Device{ID: "new"}
+	mockClient := new(autocodesign.MockDevPortalClient)
+	mockClient.On("RegisterDevice", watch).Return(registered, nil).Once()
+	mockClient.On("RegisterDevice", mac).Return(registered, nil).Once()
+
+	client := &deviceBudgetDevPortalClient{
+		DevPortalClient: mockClient,
+		listDevices: func() ([]appstoreconnect.Device, error) {
+			return []appstoreconnect.Device{
+				newTestDevice("1", appstoreconnect.Ipad, appstoreconnect.Enabled),
+				newTestDevice("2", appstoreconnect.Mac, appstoreconnect.Disabled),
+			}, nil
+		},
+		budget: 1,
+	}
+
+	device, err := client.RegisterDevice(watch)
+	assert.NoError(t, err)
+	assert.Equal(t, registered, device)
+
+	// the Apple Watch budget is used up by the just registered device
+	_, err = client.RegisterDevice(watch)
+	assert.IsType(t, appstoreconnect.DeviceRegistrationError{}, err)
+
+	// an iOS device might be an iPad
+	_, err = client.RegisterDevice(iphone)
+	assert.IsType(t, appstoreconnect.DeviceRegistrationError{}, err)
+
+	// the disabled Mac does not use up the Mac budget
+	_, err = client.RegisterDevice(mac)
+	assert.NoError(t, err)
+
+	mockClient.AssertExpectations(t)
+}
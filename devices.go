@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-xcode/devportalservice"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/devportalclient/appstoreconnect"
+)
+
+const (
+	// genericDeviceName is the name autocodesign registers every device with
+	genericDeviceName   = "Bitrise test device"
+	maxDeviceNameLength = 50
+)
+
+var unsupportedDeviceNameChars = regexp.MustCompile(`[^\p{L}\p{N} '()._-]`)
+
+// deviceName returns the test device's title, sanitized to the characters and length the Developer Portal accepts.
+func deviceName(testDevice devportalservice.TestDevice) string {
+	name := unsupportedDeviceNameChars.ReplaceAllString(testDevice.Title, " ")
+	name = strings.Join(strings.Fields(name), " ")
+
+	if runes := []rune(name); len(runes) > maxDeviceNameLength {
+		name = strings.TrimSpace(string(runes[:maxDeviceNameLength]))
+	}
+	if name == "" {
+		return genericDeviceName
+	}
+	return name
+}
+
+// devicePlatform maps the test device's type to the Developer Portal platform,
+// Apple TV and Apple Watch devices belong to the iOS platform.
+func devicePlatform(testDevice devportalservice.TestDevice) appstoreconnect.BundleIDPlatform {
+	if classes := testDeviceClasses(testDevice); len(classes) == 1 && classes[0] == appstoreconnect.Mac {
+		return appstoreconnect.MacOS
+	}
+	return appstoreconnect.IOS
+}
+
+// deviceRegisteringDevPortalClient registers the test devices with their own name and platform,
+// instead of the generic name and the iOS platform used by autocodesign.
+type deviceRegisteringDevPortalClient struct {
+	autocodesign.DevPortalClient
+
+	client *appstoreconnect.Client
+}
+
+func newDeviceRegisteringDevPortalClient(devPortalClient autocodesign.DevPortalClient, client *appstoreconnect.Client) *deviceRegisteringDevPortalClient {
+	return &deviceRegisteringDevPortalClient{
+		DevPortalClient: devPortalClient,
+		client:          client,
+	}
+}
+
+// ListDevices ...
+func (c *deviceRegisteringDevPortalClient) ListDevices(udid string, platform appstoreconnect.DevicePlatform) ([]appstoreconnect.Device, error) {
+	devices, err := c.DevPortalClient.ListDevices(udid, platform)
+	if err != nil || platform != appstoreconnect.IOSDevice {
+		return devices, err
+	}
+
+	// Mac devices are listed too, so that the already registered ones are not registered again
+	macDevices, err := c.DevPortalClient.ListDevices(udid, appstoreconnect.MacOSDevice)
+	if err != nil {
+		return nil, err
+	}
+	return append(devices, macDevices...), nil
+}
+
+// RegisterDevice ...
+func (c *deviceRegisteringDevPortalClient) RegisterDevice(testDevice devportalservice.TestDevice) (*appstoreconnect.Device, error) {
+	req := appstoreconnect.DeviceCreateRequest{
+		Data: appstoreconnect.DeviceCreateRequestData{
+			Attributes: appstoreconnect.DeviceCreateRequestDataAttributes{
+				Name:     deviceName(testDevice),
+				Platform: devicePlatform(testDevice),
+				UDID:     testDevice.DeviceID,
+			},
+			Type: "devices",
+		},
+	}
+
+	registeredDevice, err := c.client.Provisioning.RegisterNewDevice(req)
+	if err != nil {
+		var respErr *appstoreconnect.ErrorResponse
+		if ok := errors.As(err, &respErr); ok {
+			if respErr.Response != nil && respErr.Response.StatusCode == http.StatusConflict {
+				return nil, appstoreconnect.DeviceRegistrationError{
+					Reason: fmt.Sprintf("%v", err),
+				}
+			}
+		}
+
+		return nil, err
+	}
+
+	return &registeredDevice.Data, nil
+}
+
+// renameGenericDevices renames the test devices registered with the generic name to their own name.
+// If plan is not nil, the renames are recorded into it instead of being executed.
+// The devices are deduplicated by ID, as the deviceRegisteringDevPortalClient lists the Mac devices with the iOS ones too.
+func renameGenericDevices(devPortalClient autocodesign.DevPortalClient, service ProvisioningService, testDevices []devportalservice.TestDevice, plan *PortalPlan) error {
+	var devices []appstoreconnect.Device
+	listed := map[string]bool{}
+	for _, platform := range []appstoreconnect.DevicePlatform{appstoreconnect.IOSDevice, appstoreconnect.MacOSDevice} {
+		platformDevices, err := devPortalClient.ListDevices("", platform)
+		if err != nil {
+			return fmt.Errorf("failed to list devices: %s", err)
+		}
+		for _, device := range platformDevices {
+			if !listed[device.ID] {
+				listed[device.ID] = true
+				devices = append(devices, device)
+			}
+		}
+	}
+
+	for _, device := range devices {
+		if device.Attributes.Name != genericDeviceName {
+			continue
+		}
+
+		for _, testDevice := range testDevices {
+			if !devportalservice.IsEqualUDID(device.Attributes.UDID, testDevice.DeviceID) {
+				continue
+			}
+
+			name := deviceName(testDevice)
+			if name == genericDeviceName {
+				break
+			}
+
+			if plan != nil {
+				plan.record(PortalChange{Action: RenameDeviceAction, ID: device.ID, Name: name, UDID: device.Attributes.UDID})
+				break
+			}
+
+			if _, err := service.UpdateDevice(device.ID, NewDeviceUpdateRequest(device.ID, DeviceUpdateRequestDataAttributes{Name: name})); err != nil {
+				return fmt.Errorf("failed to rename device (%s): %s", device.Attributes.UDID, err)
+			}
+			log.Printf("device renamed: %s (UDID: %s)", name, device.Attributes.UDID)
+			break
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bitrise-io/go-xcode/devportalservice"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/devportalclient/appstoreconnect"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_deviceName(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{title: "John's iPhone 12 (work)", want: "John's iPhone 12 (work)"},
+		{title: "  iPad\tAir   2  ", want: "iPad Air 2"},
+		{title: "Mac mini • M1 / office", want: "Mac mini M1 office"},
+		{title: "Éva's iPhone", want: "Éva's iPhone"},
+		{title: strings.Repeat("a", 60), want: strings.Repeat("a", maxDeviceNameLength)},
+		{title: "", want: genericDeviceName},
+		{title: "***", want: genericDeviceName},
+	}
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			assert.Equal(t, tt.want, deviceName(devportalservice.TestDevice{Title: tt.title}))
+		})
+	}
+}
+
+func Test_devicePlatform(t *testing.T) {
+	for deviceType, want := range map[string]appstoreconnect.BundleIDPlatform{
+		"ios":    appstoreconnect.IOS,
+		"watch":  appstoreconnect.IOS,
+		"tvos":   appstoreconnect.IOS,
+		"mac":    appstoreconnect.MacOS,
+		"MAC_OS": appstoreconnect.MacOS,
+		"":       appstoreconnect.IOS,
+	} {
+		assert.Equal(t, want, devicePlatform(devportalservice.TestDevice{DeviceType: deviceType}), deviceType)
+	}
+}
+
+func Test_renameGenericDevices(t *testing.T) {
+	device := func(id, name, udid string) appstoreconnect.Device {
+		return appstoreconnect.Device{ID: id, Attributes: appstoreconnect.DeviceAttributes{Name: name, UDID: udid}}
+	}
+	mockClient := new(autocodesign.MockDevPortalClient)
+	mockClient.On("ListDevices", "", appstoreconnect.IOSDevice).Return([]appstoreconnect.Device{
+		device("1", genericDeviceName, "udid-1"),
+		device("2", "Named iPhone", "udid-2"),
+		device("3", genericDeviceName, "udid-unknown"),
+	}, nil)
+	mockClient.On("ListDevices", "", appstoreconnect.MacOSDevice).Return([]appstoreconnect.Device{
+		device("4", genericDeviceName, "udid-4"),
+	}, nil)
+
+	plan := &PortalPlan{}
+	err := renameGenericDevices(mockClient, ProvisioningService{}, []devportalservice.TestDevice{
+		{DeviceID: "udid-1", Title: "John's iPhone"},
+		{DeviceID: "udid-2", Title: "Other name"},
+		{DeviceID: "udid-4", Title: "Mac mini", DeviceType: "mac"},
+	}, plan)
+	assert.NoError(t, err)
+	assert.Equal(t, []PortalChange{
+		{Action: RenameDeviceAction, ID: "1", Name: "John's iPhone", UDID: "udid-1"},
+		{Action: RenameDeviceAction, ID: "4", Name: "Mac mini", UDID: "udid-4"},
+	}, plan.Changes)
+	mockClient.AssertExpectations(t)
+}
+
+func Test_renameGenericDevices_MacListedWithIOSDevices(t *testing.T) {
+	mac := appstoreconnect.Device{ID: "4", Attributes: appstoreconnect.DeviceAttributes{Name: genericDeviceName, UDID: "udid-4"}}
+	mockClient := new(autocodesign.MockDevPortalClient)
+	mockClient.On("ListDevices", "", appstoreconnect.IOSDevice).Return([]appstoreconnect.Device{}, nil)
+	mockClient.On("ListDevices", "", appstoreconnect.MacOSDevice).Return([]appstoreconnect.Device{mac}, nil)
+
+	plan := &PortalPlan{}
+	err := renameGenericDevices(newDeviceRegisteringDevPortalClient(mockClient, nil), ProvisioningService{}, []devportalservice.TestDevice{
+		{DeviceID: "udid-4", Title: "Mac mini", DeviceType: "mac"},
+	}, plan)
+	assert.NoError(t, err)
+	assert.Equal(t, []PortalChange{
+		{Action: RenameDeviceAction, ID: "4", Name: "Mac mini", UDID: "udid-4"},
+	}, plan.Changes, "the Mac device is renamed once")
+}
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"net/url"
+	"strings"
 
 	"github.com/bitrise-io/go-utils/log"
 	"github.com/bitrise-io/go-xcode/appleauth"
@@ -16,14 +19,27 @@ const notConnected = `Bitrise Apple service connection not found.
 Most likely because there is no configured Bitrise Apple service connection.
 Read more: https://devcenter.bitrise.io/getting-started/configuring-bitrise-steps-that-require-apple-developer-account-data/`
 
-func createClient(authSources []appleauth.Source, authInputs appleauth.Inputs, teamID string, conn *devportalservice.AppleDeveloperConnection) (autocodesign.DevPortalClient, error) {
+// apiClientOptions configures the App Store Connect API client
+type apiClientOptions struct {
+	// BaseURL, if set, is where the requests are sent instead of to Apple
+	BaseURL string
+	// CassettePth, if set, is the cassette file the traffic is recorded into
+	CassettePth string
+	// RateLimiter, if set, throttles the requests by the API key's rate limit
+	RateLimiter *rateLimiter
+}
+
+// createClient returns the Developer Portal client,
+// and the underlying App Store Connect API client if API key authentication is used.
+// The App Store Connect API requests are sent with the context.
+func createClient(ctx context.Context, authSources []appleauth.Source, authInputs appleauth.Inputs, teamID string, conn *devportalservice.AppleDeveloperConnection, opts apiClientOptions) (autocodesign.DevPortalClient, *appstoreconnect.Client, error) {
 	authConfig, err := appleauth.Select(conn, authSources, authInputs)
 	if err != nil {
 		if conn == nil || (conn.APIKeyConnection == nil && conn.AppleIDConnection == nil) {
 			fmt.Println()
 			log.Warnf("%s", notConnected)
 		}
-		return nil, fmt.Errorf("could not configure Apple service authentication: %v", err)
+		return nil, nil, fmt.Errorf("could not configure Apple service authentication: %v", err)
 	}
 
 	if authConfig.APIKey != nil {
@@ -38,20 +54,53 @@ func createClient(authSources []appleauth.Source, authInputs appleauth.Inputs, t
 	fmt.Println()
 	log.Infof("Initializing Developer Portal client")
 	var devportalClient autocodesign.DevPortalClient
+	var apiClient *appstoreconnect.Client
 	if authConfig.APIKey != nil {
 		httpClient := appstoreconnect.NewRetryableHTTPClient()
-		client := appstoreconnect.NewClient(httpClient, authConfig.APIKey.KeyID, authConfig.APIKey.IssuerID, []byte(authConfig.APIKey.PrivateKey))
-		client.EnableDebugLogs = false // Disable client debug logs including HTTP call debug logs
-		devportalClient = appstoreconnectclient.NewAPIDevPortalClient(client)
-		log.Donef("App Store Connect API client created with base URL: %s", client.BaseURL)
+		if opts.RateLimiter != nil {
+			httpClient = newRateLimitedHTTPClient(opts.RateLimiter)
+		}
+		if opts.CassettePth != "" {
+			httpClient = newCassetteRecordingClient(httpClient, opts.CassettePth)
+			log.Warnf("Recording App Store Connect API traffic to: %s", opts.CassettePth)
+		}
+		httpClient = newContextHTTPClient(ctx, httpClient)
+		apiClient = appstoreconnect.NewClient(httpClient, authConfig.APIKey.KeyID, authConfig.APIKey.IssuerID, []byte(authConfig.APIKey.PrivateKey))
+		apiClient.EnableDebugLogs = false // Disable client debug logs including HTTP call debug logs
+		if opts.BaseURL != "" {
+			u, err := parseAPIBaseURL(opts.BaseURL)
+			if err != nil {
+				return nil, nil, err
+			}
+			apiClient.BaseURL = u
+		}
+		devportalClient = newPagedDevPortalClient(appstoreconnectclient.NewAPIDevPortalClient(apiClient), apiClient)
+		log.Donef("App Store Connect API client created with base URL: %s", apiClient.BaseURL)
 	} else if authConfig.AppleID != nil {
 		client, err := spaceship.NewClient(*authConfig.AppleID, teamID)
 		if err != nil {
-			return nil, fmt.Errorf("failed to initialize Apple ID client: %v", err)
+			return nil, nil, fmt.Errorf("failed to initialize Apple ID client: %v", err)
 		}
 		devportalClient = spaceship.NewSpaceshipDevportalClient(client)
 		log.Donef("Apple ID client created")
 	}
 
-	return devportalClient, nil
+	return devportalClient, apiClient, nil
+}
+
+// parseAPIBaseURL parses the App Store Connect API base URL, the API version is appended to it by the client.
+func parseAPIBaseURL(apiBaseURL string) (*url.URL, error) {
+	u, err := url.Parse(apiBaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid App Store Connect API base URL (%s): %s", apiBaseURL, err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid App Store Connect API base URL (%s): scheme or host missing", apiBaseURL)
+	}
+
+	// The endpoints are resolved relative to the base URL, which drops the last path segment without a trailing slash
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+	return u, nil
 }
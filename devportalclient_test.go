@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/go-xcode/appleauth"
+	"github.com/bitrise-io/go-xcode/certificateutil"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/devportalclient/appstoreconnect"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/localcodesignasset"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseAPIBaseURL(t *testing.T) {
+	tests := []struct {
+		name       string
+		apiBaseURL string
+		want       string
+		wantErr    bool
+	}{
+		{name: "appends trailing slash", apiBaseURL: "http://127.0.0.1:8080", want: "http://127.0.0.1:8080/"},
+		{name: "keeps path", apiBaseURL: "https://example.com/appstoreconnect/", want: "https://example.com/appstoreconnect/"},
+		{name: "missing scheme", apiBaseURL: "example.com", wantErr: true},
+		{name: "invalid URL", apiBaseURL: "http://[::1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseAPIBaseURL(tt.apiBaseURL)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got.String())
+		})
+	}
+}
+
+const testProfileName = "Bitrise iOS development - (io.bitrise.app)"
+
+func newFakeDevPortalClient(t *testing.T, server *fakeAppStoreConnect, cassettePth string) autocodesign.DevPortalClient {
+	client, apiClient, err := createClient(
+		context.Background(),
+		[]appleauth.Source{&appleauth.InputAPIKeySource{}},
+		appleauth.Inputs{APIIssuer: "fake-issuer", APIKeyPath: "file://" + writeFakeAPIKey(t)},
+		"", nil, apiClientOptions{BaseURL: server.URL, CassettePth: cassettePth},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, server.URL+"/", apiClient.BaseURL.String())
+	return client
+}
+
+func ensureFakeCodesignAssets(client autocodesign.DevPortalClient, identity certificateutil.CertificateInfoModel) (map[autocodesign.DistributionType]autocodesign.AppCodesignAssets, error) {
+	certificateProvider := new(autocodesign.MockCertificateProvider)
+	certificateProvider.On("GetCertificates").Return([]certificateutil.CertificateInfoModel{identity}, nil)
+	localManager := localcodesignasset.NewManager(newProfileDirProvider(nil), newProfileDirProvider(nil))
+
+	manager := autocodesign.NewCodesignAssetManager(client, certificateProvider, dryRunAssetWriter{}, localManager)
+	return manager.EnsureCodesignAssets(autocodesign.AppLayout{
+		Platform:                               autocodesign.IOS,
+		EntitlementsByArchivableTargetBundleID: map[string]autocodesign.Entitlements{"io.bitrise.app": {}},
+	}, autocodesign.CodesignAssetsOpts{DistributionType: autocodesign.Development, MinProfileValidityDays: 0})
+}
+
+func TestCreateClient_FakeAppStoreConnect(t *testing.T) {
+	identity := newTestIdentity(t, "Apple Development: Bitrise Bot")
+
+	t.Run("creates the bundle ID and the profile", func(t *testing.T) {
+		server := newFakeAppStoreConnect(t)
+		server.AddCertificate(appstoreconnect.IOSDevelopment, identity)
+		server.AddDevice("00008030-000A", appstoreconnect.Iphone)
+
+		assets, err := ensureFakeCodesignAssets(newFakeDevPortalClient(t, server, ""), identity)
+		assert.NoError(t, err)
+
+		profile := assets[autocodesign.Development].ArchivableTargetProfilesByBundleID["io.bitrise.app"]
+		if assert.NotNil(t, profile) {
+			assert.Equal(t, testProfileName, profile.Attributes().Name)
+		}
+		if bundleIDs := server.BundleIDs(); assert.Len(t, bundleIDs, 1) {
+			assert.Equal(t, "io.bitrise.app", bundleIDs[0].Identifier)
+		}
+		if profiles := server.Profiles(); assert.Len(t, profiles, 1) {
+			assert.Len(t, profiles[0].DeviceIDs, 1)
+		}
+	})
+
+	t.Run("reuses the profile in sync, paging through the devices", func(t *testing.T) {
+		server := newFakeAppStoreConnect(t)
+		server.PageSize = 2
+		certificateID := server.AddCertificate(appstoreconnect.IOSDevelopment, identity)
+		var deviceIDs []string
+		for _, udid := range []string{"00008030-000A", "00008030-000B", "00008030-000C", "00008030-000D", "00008030-000E"} {
+			deviceIDs = append(deviceIDs, server.AddDevice(udid, appstoreconnect.Iphone))
+		}
+		bundleID := server.AddBundleID("io.bitrise.app")
+		profileID := server.AddProfile(fakeProfile{
+			Name:           testProfileName,
+			Type:           appstoreconnect.IOSAppDevelopment,
+			BundleIDID:     bundleID,
+			CertificateIDs: []string{certificateID},
+			DeviceIDs:      deviceIDs,
+			Expiry:         time.Now().AddDate(1, 0, 0),
+		})
+
+		assets, err := ensureFakeCodesignAssets(newFakeDevPortalClient(t, server, ""), identity)
+		assert.NoError(t, err)
+
+		profile := assets[autocodesign.Development].ArchivableTargetProfilesByBundleID["io.bitrise.app"]
+		if assert.NotNil(t, profile) {
+			assert.Equal(t, profileID, profile.ID())
+		}
+		assert.Len(t, server.Profiles(), 1)
+		assert.NotContains(t, server.Requests(), "POST /v1/profiles")
+	})
+
+	t.Run("deletes the expired duplicate profile", func(t *testing.T) {
+		server := newFakeAppStoreConnect(t)
+		certificateID := server.AddCertificate(appstoreconnect.IOSDevelopment, identity)
+		deviceID := server.AddDevice("00008030-000A", appstoreconnect.Iphone)
+		bundleID := server.AddBundleID("io.bitrise.app")
+		expiredID := server.AddProfile(fakeProfile{
+			Name:           testProfileName,
+			Type:           appstoreconnect.IOSAppDevelopment,
+			BundleIDID:     bundleID,
+			CertificateIDs: []string{certificateID},
+			DeviceIDs:      []string{deviceID},
+			Expiry:         time.Now().AddDate(0, 0, -1),
+		})
+
+		_, err := ensureFakeCodesignAssets(newFakeDevPortalClient(t, server, ""), identity)
+		assert.NoError(t, err)
+
+		assert.Contains(t, server.Requests(), "DELETE /v1/profiles/"+expiredID)
+		if profiles := server.Profiles(); assert.Len(t, profiles, 1) {
+			assert.NotEqual(t, expiredID, profiles[0].ID)
+			assert.False(t, profiles[0].expired())
+		}
+	})
+
+	t.Run("returns the error body", func(t *testing.T) {
+		server := newFakeAppStoreConnect(t)
+		server.AddCertificate(appstoreconnect.IOSDevelopment, identity)
+		server.AddDevice("00008030-000A", appstoreconnect.Iphone)
+		server.FailNext(http.MethodPost, "/v1/bundleIds", http.StatusConflict, "ENTITY_ERROR.ATTRIBUTE.INVALID", "An App ID with Identifier 'io.bitrise.app' is not available.")
+
+		_, err := ensureFakeCodesignAssets(newFakeDevPortalClient(t, server, ""), identity)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "An App ID with Identifier 'io.bitrise.app' is not available.")
+		}
+		assert.Empty(t, server.Profiles())
+	})
+}
+
+func TestFakeAppStoreConnect_Errors(t *testing.T) {
+	server := newFakeAppStoreConnect(t)
+
+	tests := []struct {
+		name       string
+		path       string
+		authorized bool
+		wantStatus int
+	}{
+		{name: "unauthorized", path: "/v1/profiles", wantStatus: http.StatusUnauthorized},
+		{name: "unknown profile", path: "/v1/profiles/unknown/devices", authorized: true, wantStatus: http.StatusNotFound},
+		{name: "unknown route", path: "/v1/apps", authorized: true, wantStatus: http.StatusNotFound},
+		{name: "page size over the maximum", path: "/v1/devices?limit=201", authorized: true, wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, server.URL+tt.path, nil)
+			assert.NoError(t, err)
+			if tt.authorized {
+				req.Header.Set("Authorization", "Bearer token")
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			assert.NoError(t, err)
+			defer func() { assert.NoError(t, resp.Body.Close()) }()
+
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+			var errorResponse appstoreconnect.ErrorResponse
+			assert.NoError(t, json.NewDecoder(resp.Body).Decode(&errorResponse))
+			if assert.Len(t, errorResponse.Errors, 1) {
+				assert.Equal(t, strconv.Itoa(tt.wantStatus), errorResponse.Errors[0].Status)
+			}
+		})
+	}
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-xcode/certificateutil"
+	"github.com/bitrise-io/go-xcode/devportalservice"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/devportalclient/appstoreconnect"
+)
+
+// PortalAction is a Developer Portal mutation kind
+type PortalAction string
+
+// PortalActions ...
+const (
+	DeleteProfileAction  PortalAction = "delete_profile"
+	CreateProfileAction  PortalAction = "create_profile"
+	CreateBundleIDAction PortalAction = "create_bundle_id"
+	SyncBundleIDAction   PortalAction = "sync_bundle_id"
+	RegisterDeviceAction PortalAction = "register_device"
+
+	CreateCertificateAction PortalAction = "create_certificate"
+	RevokeCertificateAction PortalAction = "revoke_certificate"
+	DeleteBundleIDAction    PortalAction = "delete_bundle_id"
+	RenameDeviceAction      PortalAction = "rename_device"
+	DisableDeviceAction     PortalAction = "disable_device"
+)
+
+// PortalChange is a Developer Portal mutation recorded instead of being executed
+type PortalChange struct {
+	Action         PortalAction                `json:"action"`
+	ID             string                      `json:"id,omitempty"`
+	Name           string                      `json:"name,omitempty"`
+	BundleID       string                      `json:"bundle_id,omitempty"`
+	ProfileType    appstoreconnect.ProfileType `json:"profile_type,omitempty"`
+	CertificateIDs []string                    `json:"certificate_ids,omitempty"`
+	DeviceIDs      []string                    `json:"device_ids,omitempty"`
+	Capabilities   []string                    `json:"capabilities,omitempty"`
+	UDID           string                      `json:"udid,omitempty"`
+
+	Platform appstoreconnect.BundleIDPlatform `json:"platform,omitempty"`
+
+	CertificateType appstoreconnect.CertificateType `json:"certificate_type,omitempty"`
+	Serial          string                          `json:"serial,omitempty"`
+}
+
+func (c PortalChange) String() string {
+	switch c.Action {
+	case DeleteProfileAction:
+		return fmt.Sprintf("delete profile: %s (ID: %s)", c.Name, c.ID)
+	case CreateProfileAction:
+		return fmt.Sprintf("create %s profile: %s (bundle ID: %s, certificates: %d, devices: %d)", readableProfileType(c.ProfileType), c.Name, c.BundleID, len(c.CertificateIDs), len(c.DeviceIDs))
+	case CreateBundleIDAction:
+		return fmt.Sprintf("create %s app ID: %s (bundle ID: %s)", c.Platform, c.Name, c.BundleID)
+	case SyncBundleIDAction:
+		return fmt.Sprintf("sync app ID capabilities: %s (bundle ID: %s, capabilities: %v)", c.Name, c.BundleID, c.Capabilities)
+	case RegisterDeviceAction:
+		return fmt.Sprintf("register device: %s (UDID: %s)", c.Name, c.UDID)
+	case DisableDeviceAction:
+		return fmt.Sprintf("disable device: %s (UDID: %s, ID: %s)", c.Name, c.UDID, c.ID)
+	case RenameDeviceAction:
+		return fmt.Sprintf("rename device to: %s (UDID: %s, ID: %s)", c.Name, c.UDID, c.ID)
+	case DeleteBundleIDAction:
+		return fmt.Sprintf("delete app ID: %s (bundle ID: %s, ID: %s)", c.Name, c.BundleID, c.ID)
+	case CreateCertificateAction:
+		return fmt.Sprintf("create %s certificate", c.CertificateType)
+	case RevokeCertificateAction:
+		return fmt.Sprintf("revoke %s certificate: %s (ID: %s, serial: %s)", c.CertificateType, c.Name, c.ID, c.Serial)
+	}
+	return string(c.Action)
+}
+
+// PortalPlan collects the Developer Portal mutations of a dry-run
+type PortalPlan struct {
+	Changes []PortalChange `json:"changes"`
+}
+
+func (p *PortalPlan) record(change PortalChange) {
+	log.Warnf("  [dry-run] %s", change)
+	p.Changes = append(p.Changes, change)
+}
+
+// Print ...
+func (p PortalPlan) Print() {
+	fmt.Println()
+	if len(p.Changes) == 0 {
+		log.Donef("Dry-run: no Developer Portal changes are needed")
+		return
+	}
+
+	log.Infof("Dry-run: the following Developer Portal changes would be made (%d)", len(p.Changes))
+	for _, change := range p.Changes {
+		log.Printf("- %s", change)
+	}
+}
+
+// WriteToFile ...
+func (p PortalPlan) WriteToFile(pth string) error {
+	b, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal plan: %s", err)
+	}
+
+	return ioutil.WriteFile(pth, b, 0600)
+}
+
+// dryRunDevPortalClient passes through the Developer Portal queries
+// and records the mutations into a PortalPlan instead of executing them.
+// The app IDs are planned with the platform of their app layout (see appIDPlatforms), iOS if not listed.
+type dryRunDevPortalClient struct {
+	autocodesign.DevPortalClient
+
+	plan           *PortalPlan
+	appIDPlatforms map[string]appstoreconnect.BundleIDPlatform
+	profileNames   map[string]string
+}
+
+func newDryRunDevPortalClient(client autocodesign.DevPortalClient, plan *PortalPlan, appIDPlatforms map[string]appstoreconnect.BundleIDPlatform) autocodesign.DevPortalClient {
+	return dryRunDevPortalClient{
+		DevPortalClient: client,
+		plan:            plan,
+		appIDPlatforms:  appIDPlatforms,
+		profileNames:    map[string]string{},
+	}
+}
+
+// RegisterDevice ...
+func (c dryRunDevPortalClient) RegisterDevice(testDevice devportalservice.TestDevice) (*appstoreconnect.Device, error) {
+	c.plan.record(PortalChange{
+		Action: RegisterDeviceAction,
+		Name:   deviceName(testDevice),
+		UDID:   testDevice.DeviceID,
+	})
+
+	return nil, nil
+}
+
+// FindProfile ...
+func (c dryRunDevPortalClient) FindProfile(name string, profileType appstoreconnect.ProfileType) (autocodesign.Profile, error) {
+	profile, err := c.DevPortalClient.FindProfile(name, profileType)
+	if err == nil && profile != nil {
+		c.profileNames[profile.ID()] = profile.Attributes().Name
+	}
+	return profile, err
+}
+
+// DeleteProfile ...
+func (c dryRunDevPortalClient) DeleteProfile(id string) error {
+	c.plan.record(PortalChange{
+		Action: DeleteProfileAction,
+		ID:     id,
+		Name:   c.profileNames[id],
+	})
+
+	return nil
+}
+
+// CreateProfile ...
+func (c dryRunDevPortalClient) CreateProfile(name string, profileType appstoreconnect.ProfileType, bundleID appstoreconnect.BundleID, certificateIDs []string, deviceIDs []string) (autocodesign.Profile, error) {
+	c.plan.record(PortalChange{
+		Action:         CreateProfileAction,
+		Name:           name,
+		BundleID:       bundleID.Attributes.Identifier,
+		ProfileType:    profileType,
+		CertificateIDs: certificateIDs,
+		DeviceIDs:      deviceIDs,
+	})
+
+	return plannedProfile{
+		attributes: appstoreconnect.ProfileAttributes{
+			Name:        name,
+			ProfileType: profileType,
+		},
+		bundleID:       bundleID,
+		certificateIDs: certificateIDs,
+		deviceIDs:      deviceIDs,
+	}, nil
+}
+
+// CreateBundleID ...
+func (c dryRunDevPortalClient) CreateBundleID(bundleIDIdentifier, appIDName string) (*appstoreconnect.BundleID, error) {
+	platform, ok := c.appIDPlatforms[bundleIDIdentifier]
+	if !ok {
+		platform = appstoreconnect.IOS
+	}
+
+	c.plan.record(PortalChange{
+		Action:   CreateBundleIDAction,
+		Name:     appIDName,
+		BundleID: bundleIDIdentifier,
+		Platform: platform,
+	})
+
+	return &appstoreconnect.BundleID{
+		Attributes: appstoreconnect.BundleIDAttributes{
+			Identifier: bundleIDIdentifier,
+			Name:       appIDName,
+			Platform:   string(platform),
+		},
+		Type: "bundleIds",
+	}, nil
+}
+
+// SyncBundleID ...
+func (c dryRunDevPortalClient) SyncBundleID(bundleID appstoreconnect.BundleID, appEntitlements autocodesign.Entitlements) error {
+	var capabilities []string
+	for key, value := range appEntitlements {
+		if (autocodesign.Entitlement{key: value}).AppearsOnDeveloperPortal() {
+			capabilities = append(capabilities, key)
+		}
+	}
+	sort.Strings(capabilities)
+
+	c.plan.record(PortalChange{
+		Action:       SyncBundleIDAction,
+		ID:           bundleID.ID,
+		Name:         bundleID.Attributes.Name,
+		BundleID:     bundleID.Attributes.Identifier,
+		Capabilities: capabilities,
+	})
+
+	return nil
+}
+
+// plannedProfile is a profile which would be created on the Developer Portal
+type plannedProfile struct {
+	attributes     appstoreconnect.ProfileAttributes
+	bundleID       appstoreconnect.BundleID
+	certificateIDs []string
+	deviceIDs      []string
+}
+
+// ID ...
+func (p plannedProfile) ID() string {
+	return ""
+}
+
+// Attributes ...
+func (p plannedProfile) Attributes() appstoreconnect.ProfileAttributes {
+	return p.attributes
+}
+
+// CertificateIDs ...
+func (p plannedProfile) CertificateIDs() ([]string, error) {
+	return p.certificateIDs, nil
+}
+
+// DeviceIDs ...
+func (p plannedProfile) DeviceIDs() ([]string, error) {
+	return p.deviceIDs, nil
+}
+
+// BundleID ...
+func (p plannedProfile) BundleID() (appstoreconnect.BundleID, error) {
+	return p.bundleID, nil
+}
+
+// Entitlements ...
+func (p plannedProfile) Entitlements() (autocodesign.Entitlements, error) {
+	return nil, nil
+}
+
+// dryRunAssetWriter leaves the keychain and the installed profiles untouched
+type dryRunAssetWriter struct{}
+
+// Write ...
+func (w dryRunAssetWriter) Write(codesignAssetsByDistributionType map[autocodesign.DistributionType]autocodesign.AppCodesignAssets) error {
+	for distrType, codesignAssets := range codesignAssetsByDistributionType {
+		log.Printf("[dry-run] skipping %s certificate and profile installation", distrType)
+		log.Printf("certificate: %s", codesignAssets.Certificate.CommonName)
+	}
+	return nil
+}
+
+// InstallCertificate ...
+func (w dryRunAssetWriter) InstallCertificate(certificate certificateutil.CertificateInfoModel) error {
+	log.Printf("[dry-run] skipping certificate installation: %s", certificate.CommonName)
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/bitrise-io/go-xcode/devportalservice"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/devportalclient/appstoreconnect"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDryRunDevPortalClient_RecordsMutations(t *testing.T) {
+	mockClient := &autocodesign.MockDevPortalClient{}
+	plan := &PortalPlan{}
+	client := newDryRunDevPortalClient(mockClient, plan, nil)
+
+	bundleID, err := client.CreateBundleID("io.bitrise.app", "Bitrise io bitrise app")
+	assert.NoError(t, err)
+	assert.NoError(t, client.SyncBundleID(*bundleID, autocodesign.Entitlements{"com.apple.developer.homekit": true}))
+
+	profile, err := client.CreateProfile("Bitrise iOS development - (io.bitrise.app)", appstoreconnect.IOSAppDevelopment, *bundleID, []string{"cert-id"}, []string{"device-id"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Bitrise iOS development - (io.bitrise.app)", profile.Attributes().Name)
+
+	assert.NoError(t, client.DeleteProfile("profile-id"))
+
+	device, err := client.RegisterDevice(devportalservice.TestDevice{DeviceID: "udid", Title: "iPhone"})
+	assert.NoError(t, err)
+	assert.Nil(t, device)
+
+	var actions []PortalAction
+	for _, change := range plan.Changes {
+		actions = append(actions, change.Action)
+	}
+	assert.Equal(t, []PortalAction{CreateBundleIDAction, SyncBundleIDAction, CreateProfileAction, DeleteProfileAction, RegisterDeviceAction}, actions)
+	assert.Equal(t, []string{"com.apple.developer.homekit"}, plan.Changes[1].Capabilities)
+	assert.Equal(t, appstoreconnect.IOS, plan.Changes[0].Platform)
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestDryRunDevPortalClient_CreateBundleID_Platform(t *testing.T) {
+	macAppLayout := autocodesign.AppLayout{
+		Platform:                               autocodesign.MacOS,
+		EntitlementsByArchivableTargetBundleID: map[string]autocodesign.Entitlements{"io.bitrise.mac": nil},
+	}
+	plan := &PortalPlan{}
+	client := newDryRunDevPortalClient(&autocodesign.MockDevPortalClient{}, plan, appIDPlatforms(macAppLayout))
+
+	bundleID, err := client.CreateBundleID("io.bitrise.mac", "Bitrise io bitrise mac")
+	assert.NoError(t, err)
+	assert.Equal(t, string(appstoreconnect.MacOS), bundleID.Attributes.Platform)
+	if assert.Len(t, plan.Changes, 1) {
+		assert.Equal(t, appstoreconnect.MacOS, plan.Changes[0].Platform)
+		assert.Equal(t, "create MAC_OS app ID: Bitrise io bitrise mac (bundle ID: io.bitrise.mac)", plan.Changes[0].String())
+	}
+}
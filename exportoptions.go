@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/bitrise-io/go-xcode/exportoptions"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign"
+)
+
+// usesICloudContainers reports whether any of the archivable targets uses iCloud containers,
+// in which case the export options need to specify the iCloud container environment.
+func usesICloudContainers(appLayout autocodesign.AppLayout) (bool, error) {
+	for bundleID, entitlements := range appLayout.EntitlementsByArchivableTargetBundleID {
+		containers, err := entitlements.ICloudContainers()
+		if err != nil {
+			return false, fmt.Errorf("failed to read iCloud containers of %s: %s", bundleID, err)
+		}
+		if len(containers) > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// newExportOptions creates the export options matching the code signing assets ensured for the given distribution type.
+// The installer certificate is only used by Mac App Store exports.
+func newExportOptions(distribution autocodesign.DistributionType, assets autocodesign.AppCodesignAssets, usesICloud bool, installerCertificate string) (exportoptions.ExportOptions, error) {
+	method, err := exportoptions.ParseMethod(string(distribution))
+	if err != nil {
+		return nil, err
+	}
+
+	profileNameByBundleID := map[string]string{}
+	for bundleID, profile := range assets.ArchivableTargetProfilesByBundleID {
+		profileNameByBundleID[bundleID] = profile.Attributes().Name
+	}
+
+	var iCloudContainerEnvironment exportoptions.ICloudContainerEnvironment
+	if usesICloud {
+		iCloudContainerEnvironment = exportoptions.ICloudContainerEnvironmentProduction
+		if method == exportoptions.MethodDevelopment {
+			iCloudContainerEnvironment = exportoptions.ICloudContainerEnvironmentDevelopment
+		}
+	}
+
+	if method == exportoptions.MethodAppStore {
+		options := exportoptions.NewAppStoreOptions()
+		options.TeamID = assets.Certificate.TeamID
+		options.SigningCertificate = assets.Certificate.CommonName
+		options.InstallerSigningCertificate = installerCertificate
+		options.SigningStyle = "manual"
+		options.BundleIDProvisioningProfileMapping = profileNameByBundleID
+		options.ICloudContainerEnvironment = iCloudContainerEnvironment
+		return options, nil
+	}
+
+	options := exportoptions.NewNonAppStoreOptions(method)
+	options.TeamID = assets.Certificate.TeamID
+	options.SigningCertificate = assets.Certificate.CommonName
+	options.SigningStyle = "manual"
+	options.BundleIDProvisioningProfileMapping = profileNameByBundleID
+	options.ICloudContainerEnvironment = iCloudContainerEnvironment
+	return options, nil
+}
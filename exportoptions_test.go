@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/bitrise-io/go-xcode/certificateutil"
+	"github.com/bitrise-io/go-xcode/exportoptions"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/devportalclient/appstoreconnect"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewExportOptions(t *testing.T) {
+	assets := autocodesign.AppCodesignAssets{
+		ArchivableTargetProfilesByBundleID: map[string]autocodesign.Profile{
+			"io.bitrise.app":       plannedProfile{attributes: appstoreconnect.ProfileAttributes{Name: "Bitrise iOS ad-hoc - (io.bitrise.app)"}},
+			"io.bitrise.app.share": plannedProfile{attributes: appstoreconnect.ProfileAttributes{Name: "Bitrise iOS ad-hoc - (io.bitrise.app.share)"}},
+		},
+		Certificate: certificateutil.CertificateInfoModel{CommonName: "Apple Distribution: Bitrise Bot (TEAM)", TeamID: "TEAM"},
+	}
+
+	options, err := newExportOptions(autocodesign.AdHoc, assets, true, "")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		exportoptions.MethodKey:                     exportoptions.MethodAdHoc,
+		exportoptions.TeamIDKey:                     "TEAM",
+		exportoptions.SigningCertificateKey:         "Apple Distribution: Bitrise Bot (TEAM)",
+		exportoptions.SigningStyleKey:               "manual",
+		exportoptions.ICloudContainerEnvironmentKey: exportoptions.ICloudContainerEnvironmentProduction,
+		exportoptions.ProvisioningProfilesKey: map[string]string{
+			"io.bitrise.app":       "Bitrise iOS ad-hoc - (io.bitrise.app)",
+			"io.bitrise.app.share": "Bitrise iOS ad-hoc - (io.bitrise.app.share)",
+		},
+	}, options.Hash())
+}
+
+func TestNewExportOptions_DeveloperID(t *testing.T) {
+	assets := autocodesign.AppCodesignAssets{
+		ArchivableTargetProfilesByBundleID: map[string]autocodesign.Profile{
+			"io.bitrise.mac": plannedProfile{attributes: appstoreconnect.ProfileAttributes{Name: "Bitrise macOS developer-id - (io.bitrise.mac)"}},
+		},
+		Certificate: certificateutil.CertificateInfoModel{CommonName: "Developer ID Application: Bitrise (TEAM)", TeamID: "TEAM"},
+	}
+
+	options, err := newExportOptions(DeveloperID, assets, false, "")
+	assert.NoError(t, err)
+	assert.Equal(t, exportoptions.MethodDeveloperID, options.Hash()[exportoptions.MethodKey])
+	assert.Equal(t, "Developer ID Application: Bitrise (TEAM)", options.Hash()[exportoptions.SigningCertificateKey])
+}
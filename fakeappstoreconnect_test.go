@@ -0,0 +1,850 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/go-xcode/certificateutil"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/devportalclient/appstoreconnect"
+	"github.com/stretchr/testify/assert"
+)
+
+// appleAPIURL prefixes the relationship and paging links, as the client only strips Apple's base URL from them
+const appleAPIURL = "https://api.appstoreconnect.apple.com/v1"
+
+const (
+	fakeDefaultPageSize = 20
+	fakeMaxPageSize     = 200
+)
+
+type fakeCertificate struct {
+	ID          string
+	Type        appstoreconnect.CertificateType
+	Certificate certificateutil.CertificateInfoModel
+}
+
+type fakeDevice struct {
+	ID       string
+	UDID     string
+	Name     string
+	Class    appstoreconnect.DeviceClass
+	Platform appstoreconnect.BundleIDPlatform
+	Status   appstoreconnect.Status
+}
+
+type fakeBundleID struct {
+	ID           string
+	Identifier   string
+	Name         string
+	Platform     appstoreconnect.BundleIDPlatform
+	Capabilities []appstoreconnect.CapabilityType
+}
+
+type fakeProfile struct {
+	ID             string
+	Name           string
+	UUID           string
+	Type           appstoreconnect.ProfileType
+	BundleIDID     string
+	CertificateIDs []string
+	DeviceIDs      []string
+	Expiry         time.Time
+}
+
+func (p fakeProfile) expired() bool {
+	return p.Expiry.Before(time.Now())
+}
+
+type fakeFailure struct {
+	method string
+	path   string
+	status int
+	code   string
+	detail string
+}
+
+// fakeAppStoreConnect is an in-memory App Store Connect API server,
+// implementing the provisioning endpoints used by appstoreconnect.ProvisioningService.
+// Like Apple's API it pages the lists (links.next), applies the filter parameters,
+// does not list the expired profiles, but refuses creating a profile with the name of an expired one.
+type fakeAppStoreConnect struct {
+	*httptest.Server
+
+	// PageSize limits the page size below the requested limit, to exercise paging
+	PageSize int
+	// RateLimit, if set, is the hourly request limit reported in the X-Rate-Limit header,
+	// the requests over it are refused with 429 (Too Many Requests)
+	RateLimit int
+	TeamID    string
+
+	mu           sync.Mutex
+	lastID       int
+	certificates []fakeCertificate
+	devices      []fakeDevice
+	bundleIDs    []fakeBundleID
+	profiles     []fakeProfile
+	failures     []fakeFailure
+	requests     []string
+}
+
+func newFakeAppStoreConnect(t *testing.T) *fakeAppStoreConnect {
+	f := &fakeAppStoreConnect{TeamID: "TEAMID"}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	t.Cleanup(f.Close)
+	return f
+}
+
+// AddCertificate ...
+func (f *fakeAppStoreConnect) AddCertificate(certificateType appstoreconnect.CertificateType, cert certificateutil.CertificateInfoModel) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := f.newID("cert")
+	f.certificates = append(f.certificates, fakeCertificate{ID: id, Type: certificateType, Certificate: cert})
+	return id
+}
+
+// AddDevice ...
+func (f *fakeAppStoreConnect) AddDevice(udid string, class appstoreconnect.DeviceClass) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := f.newID("device")
+	platform := appstoreconnect.IOS
+	if class == appstoreconnect.Mac {
+		platform = appstoreconnect.MacOS
+	}
+	f.devices = append(f.devices, fakeDevice{ID: id, UDID: udid, Name: "Device " + udid, Class: class, Platform: platform, Status: appstoreconnect.Enabled})
+	return id
+}
+
+// AddBundleID ...
+func (f *fakeAppStoreConnect) AddBundleID(identifier string, capabilities ...appstoreconnect.CapabilityType) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := f.newID("bundle")
+	f.bundleIDs = append(f.bundleIDs, fakeBundleID{ID: id, Identifier: identifier, Name: identifier, Platform: appstoreconnect.IOS, Capabilities: capabilities})
+	return id
+}
+
+// AddProfile ...
+func (f *fakeAppStoreConnect) AddProfile(profile fakeProfile) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	profile.ID = f.newID("profile")
+	if profile.UUID == "" {
+		profile.UUID = profile.ID + "-uuid"
+	}
+	f.profiles = append(f.profiles, profile)
+	return profile.ID
+}
+
+// FailNext makes the next request of the method and path (for example, `/v1/profiles`) fail with an error body.
+func (f *fakeAppStoreConnect) FailNext(method, path string, status int, code, detail string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failures = append(f.failures, fakeFailure{method: method, path: path, status: status, code: code, detail: detail})
+}
+
+// Profiles returns the profiles, including the expired ones.
+func (f *fakeAppStoreConnect) Profiles() []fakeProfile {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]fakeProfile{}, f.profiles...)
+}
+
+// BundleIDs ...
+func (f *fakeAppStoreConnect) BundleIDs() []fakeBundleID {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]fakeBundleID{}, f.bundleIDs...)
+}
+
+// Devices ...
+func (f *fakeAppStoreConnect) Devices() []fakeDevice {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]fakeDevice{}, f.devices...)
+}
+
+// Requests returns the served requests, as `METHOD /path`.
+func (f *fakeAppStoreConnect) Requests() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string{}, f.requests...)
+}
+
+func (f *fakeAppStoreConnect) newID(prefix string) string {
+	f.lastID++
+	return fmt.Sprintf("%s-%d", prefix, f.lastID)
+}
+
+func (f *fakeAppStoreConnect) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// The relationship links are requested with a double slash (v1//profiles/ID/devices)
+	segments := strings.FieldsFunc(r.URL.Path, func(c rune) bool { return c == '/' })
+	path := "/" + strings.Join(segments, "/")
+	f.requests = append(f.requests, r.Method+" "+path)
+
+	if f.RateLimit > 0 {
+		remaining := f.RateLimit - len(f.requests)
+		if remaining < 0 {
+			remaining = 0
+		}
+		w.Header().Set("X-Rate-Limit", fmt.Sprintf("user-hour-lim:%d;user-hour-rem:%d;", f.RateLimit, remaining))
+		if len(f.requests) > f.RateLimit {
+			w.Header().Set("Retry-After", "0")
+			writeFakeError(w, http.StatusTooManyRequests, "RATE_LIMIT_EXCEEDED", "The request rate limit has been reached.")
+			return
+		}
+	}
+
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+		writeFakeError(w, http.StatusUnauthorized, "NOT_AUTHORIZED", "Authentication credentials are missing or invalid.")
+		return
+	}
+
+	for i, failure := range f.failures {
+		if failure.method == r.Method && failure.path == path {
+			f.failures = append(f.failures[:i], f.failures[i+1:]...)
+			writeFakeError(w, failure.status, failure.code, failure.detail)
+			return
+		}
+	}
+
+	if len(segments) < 2 || segments[0] != "v1" {
+		writeFakeError(w, http.StatusNotFound, "NOT_FOUND", "The path provided does not match a defined resource type.")
+		return
+	}
+
+	switch resource, rest := segments[1], segments[2:]; {
+	case resource == "certificates" && len(rest) == 0 && r.Method == http.MethodGet:
+		f.listCertificates(w, r)
+	case resource == "devices" && len(rest) == 0 && r.Method == http.MethodGet:
+		f.listDevices(w, r, nil)
+	case resource == "devices" && len(rest) == 0 && r.Method == http.MethodPost:
+		f.registerDevice(w, r)
+	case resource == "bundleIds" && len(rest) == 0 && r.Method == http.MethodGet:
+		f.listBundleIDs(w, r)
+	case resource == "bundleIds" && len(rest) == 0 && r.Method == http.MethodPost:
+		f.createBundleID(w, r)
+	case resource == "bundleIds" && len(rest) == 2 && rest[1] == "bundleIdCapabilities" && r.Method == http.MethodGet:
+		f.listCapabilities(w, rest[0])
+	case resource == "bundleIds" && len(rest) == 2 && rest[1] == "profiles" && r.Method == http.MethodGet:
+		f.listBundleIDProfiles(w, r, rest[0])
+	case resource == "bundleIdCapabilities" && len(rest) == 0 && r.Method == http.MethodPost:
+		f.enableCapability(w, r)
+	case resource == "profiles" && len(rest) == 0 && r.Method == http.MethodGet:
+		f.listProfiles(w, r)
+	case resource == "profiles" && len(rest) == 0 && r.Method == http.MethodPost:
+		f.createProfile(w, r)
+	case resource == "profiles" && len(rest) == 1 && r.Method == http.MethodDelete:
+		f.deleteProfile(w, rest[0])
+	case resource == "profiles" && len(rest) == 2 && r.Method == http.MethodGet:
+		f.profileRelationship(w, r, rest[0], rest[1])
+	default:
+		writeFakeError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("The resource '%s %s' does not exist.", r.Method, path))
+	}
+}
+
+func (f *fakeAppStoreConnect) listCertificates(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var resources []interface{}
+	for _, cert := range f.certificates {
+		if !matchesFilter(query, "filter[certificateType]", string(cert.Type)) {
+			continue
+		}
+		// The serial number filter is hexadecimal
+		if serial := query.Get("filter[serialNumber]"); serial != "" && !strings.EqualFold(serial, cert.Certificate.Certificate.SerialNumber.Text(16)) {
+			continue
+		}
+		resources = append(resources, f.certificateResource(cert))
+	}
+	f.writePage(w, r, resources)
+}
+
+func (f *fakeAppStoreConnect) listDevices(w http.ResponseWriter, r *http.Request, ids []string) {
+	query := r.URL.Query()
+	var resources []interface{}
+	for _, device := range f.devices {
+		if ids != nil && !containsString(ids, device.ID) {
+			continue
+		}
+		if !matchesFilter(query, "filter[udid]", device.UDID) || !matchesFilter(query, "filter[platform]", string(device.Platform)) || !matchesFilter(query, "filter[status]", string(device.Status)) {
+			continue
+		}
+		resources = append(resources, deviceResource(device))
+	}
+	f.writePage(w, r, resources)
+}
+
+func (f *fakeAppStoreConnect) registerDevice(w http.ResponseWriter, r *http.Request) {
+	var body appstoreconnect.DeviceCreateRequest
+	if !decodeFakeBody(w, r, &body) {
+		return
+	}
+
+	for _, device := range f.devices {
+		if strings.EqualFold(device.UDID, body.Data.Attributes.UDID) {
+			writeFakeError(w, http.StatusConflict, "ENTITY_ERROR.ATTRIBUTE.INVALID", "A device with number '"+device.UDID+"' already exists on this team.")
+			return
+		}
+	}
+
+	device := fakeDevice{
+		ID:       f.newID("device"),
+		UDID:     body.Data.Attributes.UDID,
+		Name:     body.Data.Attributes.Name,
+		Class:    appstoreconnect.Iphone,
+		Platform: body.Data.Attributes.Platform,
+		Status:   appstoreconnect.Enabled,
+	}
+	f.devices = append(f.devices, device)
+	writeFakeJSON(w, http.StatusCreated, map[string]interface{}{"data": deviceResource(device)})
+}
+
+func (f *fakeAppStoreConnect) listBundleIDs(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var resources []interface{}
+	for _, bundleID := range f.bundleIDs {
+		// Like Apple's API, the identifier filter matches the bundle IDs containing the value
+		if identifier := query.Get("filter[identifier]"); identifier != "" && !strings.Contains(bundleID.Identifier, identifier) {
+			continue
+		}
+		resources = append(resources, bundleIDResource(bundleID))
+	}
+	f.writePage(w, r, resources)
+}
+
+func (f *fakeAppStoreConnect) createBundleID(w http.ResponseWriter, r *http.Request) {
+	var body appstoreconnect.BundleIDCreateRequest
+	if !decodeFakeBody(w, r, &body) {
+		return
+	}
+
+	for _, bundleID := range f.bundleIDs {
+		if bundleID.Identifier == body.Data.Attributes.Identifier {
+			writeFakeError(w, http.StatusConflict, "ENTITY_ERROR.ATTRIBUTE.INVALID", "An App ID with Identifier '"+bundleID.Identifier+"' is not available. Please enter a different string.")
+			return
+		}
+	}
+
+	bundleID := fakeBundleID{
+		ID:         f.newID("bundle"),
+		Identifier: body.Data.Attributes.Identifier,
+		Name:       body.Data.Attributes.Name,
+		Platform:   body.Data.Attributes.Platform,
+	}
+	f.bundleIDs = append(f.bundleIDs, bundleID)
+	writeFakeJSON(w, http.StatusCreated, map[string]interface{}{"data": bundleIDResource(bundleID)})
+}
+
+func (f *fakeAppStoreConnect) listCapabilities(w http.ResponseWriter, bundleIDID string) {
+	bundleID := f.findBundleID(bundleIDID)
+	if bundleID == nil {
+		writeFakeError(w, http.StatusNotFound, "NOT_FOUND", "There is no resource of type 'bundleIds' with id '"+bundleIDID+"'")
+		return
+	}
+
+	resources := []interface{}{}
+	for _, capability := range bundleID.Capabilities {
+		resources = append(resources, map[string]interface{}{
+			"type":       "bundleIdCapabilities",
+			"id":         bundleID.ID + "_" + string(capability),
+			"attributes": map[string]interface{}{"capabilityType": capability},
+		})
+	}
+	writeFakeJSON(w, http.StatusOK, map[string]interface{}{"data": resources})
+}
+
+func (f *fakeAppStoreConnect) enableCapability(w http.ResponseWriter, r *http.Request) {
+	var body appstoreconnect.BundleIDCapabilityCreateRequest
+	if !decodeFakeBody(w, r, &body) {
+		return
+	}
+
+	bundleID := f.findBundleID(body.Data.Relationships.BundleID.Data.ID)
+	if bundleID == nil {
+		writeFakeError(w, http.StatusNotFound, "NOT_FOUND", "There is no resource of type 'bundleIds' with id '"+body.Data.Relationships.BundleID.Data.ID+"'")
+		return
+	}
+
+	capability := body.Data.Attributes.CapabilityType
+	bundleID.Capabilities = append(bundleID.Capabilities, capability)
+	writeFakeJSON(w, http.StatusCreated, map[string]interface{}{"data": map[string]interface{}{
+		"type":       "bundleIdCapabilities",
+		"id":         bundleID.ID + "_" + string(capability),
+		"attributes": map[string]interface{}{"capabilityType": capability},
+	}})
+}
+
+func (f *fakeAppStoreConnect) listProfiles(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var resources []interface{}
+	for _, profile := range f.profiles {
+		// Expired profiles are not listed by the profiles endpoint
+		if profile.expired() {
+			continue
+		}
+		if !matchesFilter(query, "filter[name]", profile.Name) || !matchesFilter(query, "filter[profileType]", string(profile.Type)) || !matchesFilter(query, "filter[profileState]", string(appstoreconnect.Active)) {
+			continue
+		}
+
+		resource, err := f.profileResource(profile)
+		if err != nil {
+			writeFakeError(w, http.StatusInternalServerError, "UNEXPECTED_ERROR", err.Error())
+			return
+		}
+		resources = append(resources, resource)
+	}
+	f.writePage(w, r, resources)
+}
+
+func (f *fakeAppStoreConnect) listBundleIDProfiles(w http.ResponseWriter, r *http.Request, bundleIDID string) {
+	if f.findBundleID(bundleIDID) == nil {
+		writeFakeError(w, http.StatusNotFound, "NOT_FOUND", "There is no resource of type 'bundleIds' with id '"+bundleIDID+"'")
+		return
+	}
+
+	var resources []interface{}
+	for _, profile := range f.profiles {
+		if profile.BundleIDID != bundleIDID {
+			continue
+		}
+
+		resource, err := f.profileResource(profile)
+		if err != nil {
+			writeFakeError(w, http.StatusInternalServerError, "UNEXPECTED_ERROR", err.Error())
+			return
+		}
+		resources = append(resources, resource)
+	}
+	f.writePage(w, r, resources)
+}
+
+func (f *fakeAppStoreConnect) createProfile(w http.ResponseWriter, r *http.Request) {
+	var body appstoreconnect.ProfileCreateRequest
+	if !decodeFakeBody(w, r, &body) {
+		return
+	}
+
+	for _, profile := range f.profiles {
+		if profile.Name == body.Data.Attributes.Name {
+			writeFakeError(w, http.StatusConflict, "ENTITY_ERROR.ATTRIBUTE.INVALID", "Multiple profiles found with the name '"+profile.Name+"'.  Please remove the duplicate profiles and try again.")
+			return
+		}
+	}
+
+	bundleIDID := body.Data.Relationships.BundleID.Data.ID
+	if f.findBundleID(bundleIDID) == nil {
+		writeFakeError(w, http.StatusNotFound, "NOT_FOUND", "There is no resource of type 'bundleIds' with id '"+bundleIDID+"'")
+		return
+	}
+
+	profile := fakeProfile{
+		ID:         f.newID("profile"),
+		Name:       body.Data.Attributes.Name,
+		Type:       body.Data.Attributes.ProfileType,
+		BundleIDID: bundleIDID,
+		Expiry:     time.Now().AddDate(1, 0, 0),
+	}
+	profile.UUID = profile.ID + "-uuid"
+	for _, cert := range body.Data.Relationships.Certificates.Data {
+		profile.CertificateIDs = append(profile.CertificateIDs, cert.ID)
+	}
+	for _, device := range body.Data.Relationships.Devices.Data {
+		profile.DeviceIDs = append(profile.DeviceIDs, device.ID)
+	}
+	f.profiles = append(f.profiles, profile)
+
+	resource, err := f.profileResource(profile)
+	if err != nil {
+		writeFakeError(w, http.StatusInternalServerError, "UNEXPECTED_ERROR", err.Error())
+		return
+	}
+	writeFakeJSON(w, http.StatusCreated, map[string]interface{}{"data": resource})
+}
+
+func (f *fakeAppStoreConnect) deleteProfile(w http.ResponseWriter, id string) {
+	for i, profile := range f.profiles {
+		if profile.ID == id {
+			f.profiles = append(f.profiles[:i], f.profiles[i+1:]...)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	writeFakeError(w, http.StatusNotFound, "NOT_FOUND", "There is no resource of type 'profiles' with id '"+id+"'")
+}
+
+func (f *fakeAppStoreConnect) profileRelationship(w http.ResponseWriter, r *http.Request, id, relationship string) {
+	var profile *fakeProfile
+	for i := range f.profiles {
+		if f.profiles[i].ID == id {
+			profile = &f.profiles[i]
+		}
+	}
+	if profile == nil {
+		writeFakeError(w, http.StatusNotFound, "NOT_FOUND", "There is no resource of type 'profiles' with id '"+id+"'")
+		return
+	}
+
+	switch relationship {
+	case "bundleId":
+		writeFakeJSON(w, http.StatusOK, map[string]interface{}{"data": bundleIDResource(*f.findBundleID(profile.BundleIDID))})
+	case "certificates":
+		var resources []interface{}
+		for _, cert := range f.certificates {
+			if containsString(profile.CertificateIDs, cert.ID) {
+				resources = append(resources, f.certificateResource(cert))
+			}
+		}
+		f.writePage(w, r, resources)
+	case "devices":
+		f.listDevices(w, r, profile.DeviceIDs)
+	default:
+		writeFakeError(w, http.StatusNotFound, "NOT_FOUND", "The relationship '"+relationship+"' does not exist.")
+	}
+}
+
+func (f *fakeAppStoreConnect) findBundleID(id string) *fakeBundleID {
+	for i := range f.bundleIDs {
+		if f.bundleIDs[i].ID == id {
+			return &f.bundleIDs[i]
+		}
+	}
+	return nil
+}
+
+func (f *fakeAppStoreConnect) certificateResource(cert fakeCertificate) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "certificates",
+		"id":   cert.ID,
+		"attributes": map[string]interface{}{
+			"certificateContent": cert.Certificate.Certificate.Raw,
+			"displayName":        cert.Certificate.CommonName,
+			"name":               cert.Certificate.CommonName,
+			"expirationDate":     cert.Certificate.EndDate.Format(time.RFC3339),
+			"serialNumber":       cert.Certificate.Serial,
+			"certificateType":    cert.Type,
+			"platform":           appstoreconnect.IOS,
+		},
+	}
+}
+
+func deviceResource(device fakeDevice) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "devices",
+		"id":   device.ID,
+		"attributes": map[string]interface{}{
+			"udid":        device.UDID,
+			"name":        device.Name,
+			"deviceClass": device.Class,
+			"platform":    device.Platform,
+			"status":      device.Status,
+		},
+	}
+}
+
+func bundleIDResource(bundleID fakeBundleID) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "bundleIds",
+		"id":   bundleID.ID,
+		"attributes": map[string]interface{}{
+			"identifier": bundleID.Identifier,
+			"name":       bundleID.Name,
+			"platform":   bundleID.Platform,
+		},
+		"relationships": map[string]interface{}{
+			"profiles":             relationshipLinks("bundleIds", bundleID.ID, "profiles"),
+			"bundleIdCapabilities": relationshipLinks("bundleIds", bundleID.ID, "bundleIdCapabilities"),
+		},
+	}
+}
+
+func (f *fakeAppStoreConnect) profileResource(profile fakeProfile) (map[string]interface{}, error) {
+	content, err := f.profileContent(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	state := appstoreconnect.Active
+	if profile.expired() {
+		state = appstoreconnect.Invalid
+	}
+
+	return map[string]interface{}{
+		"type": "profiles",
+		"id":   profile.ID,
+		"attributes": map[string]interface{}{
+			"name":           profile.Name,
+			"uuid":           profile.UUID,
+			"platform":       appstoreconnect.IOS,
+			"profileType":    profile.Type,
+			"profileState":   state,
+			"profileContent": content,
+			"expirationDate": profile.Expiry.Format(time.RFC3339),
+		},
+		"relationships": map[string]interface{}{
+			"bundleId":     relationshipLinks("profiles", profile.ID, "bundleId"),
+			"certificates": relationshipLinks("profiles", profile.ID, "certificates"),
+			"devices":      relationshipLinks("profiles", profile.ID, "devices"),
+		},
+	}, nil
+}
+
+// profileContent returns an unsigned provisioning profile, which parses as the profile the Developer Portal would generate.
+func (f *fakeAppStoreConnect) profileContent(profile fakeProfile) ([]byte, error) {
+	bundleID := f.findBundleID(profile.BundleIDID)
+	if bundleID == nil {
+		return nil, fmt.Errorf("bundle ID (%s) of profile (%s) not found", profile.BundleIDID, profile.ID)
+	}
+
+	entitlements := map[string]interface{}{
+		"application-identifier":              f.TeamID + "." + bundleID.Identifier,
+		"com.apple.developer.team-identifier": f.TeamID,
+		"get-task-allow":                      profileTypeDistribution(profile.Type) == autocodesign.Development,
+	}
+	for _, capability := range bundleID.Capabilities {
+		for key, serviceType := range appstoreconnect.ServiceTypeByKey {
+			if serviceType == capability {
+				entitlements[key] = true
+			}
+		}
+	}
+
+	var certificates [][]byte
+	for _, cert := range f.certificates {
+		if containsString(profile.CertificateIDs, cert.ID) {
+			certificates = append(certificates, cert.Certificate.Certificate.Raw)
+		}
+	}
+	var udids []string
+	for _, device := range f.devices {
+		if containsString(profile.DeviceIDs, device.ID) {
+			udids = append(udids, device.UDID)
+		}
+	}
+
+	return newFakeProfileContent(profile.Name, profile.UUID, f.TeamID, profile.Expiry, entitlements, certificates, udids)
+}
+
+func relationshipLinks(resource, id, relationship string) map[string]interface{} {
+	return map[string]interface{}{
+		"links": map[string]string{
+			"self":    fmt.Sprintf("%s/%s/%s/relationships/%s", appleAPIURL, resource, id, relationship),
+			"related": fmt.Sprintf("%s/%s/%s/%s", appleAPIURL, resource, id, relationship),
+		},
+	}
+}
+
+// writePage writes a page of the resources, selected by the limit and cursor parameters.
+func (f *fakeAppStoreConnect) writePage(w http.ResponseWriter, r *http.Request, resources []interface{}) {
+	query := r.URL.Query()
+
+	limit := fakeDefaultPageSize
+	if value := query.Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > fakeMaxPageSize {
+			writeFakeError(w, http.StatusBadRequest, "PARAMETER_ERROR.INVALID", fmt.Sprintf("'%s' is not a valid value for the parameter 'limit', the maximum is %d", value, fakeMaxPageSize))
+			return
+		}
+	}
+	if f.PageSize > 0 && f.PageSize < limit {
+		limit = f.PageSize
+	}
+
+	offset := 0
+	if cursor := query.Get("cursor"); cursor != "" {
+		decoded, err := base64.StdEncoding.DecodeString(cursor)
+		if err == nil {
+			offset, err = strconv.Atoi(string(decoded))
+		}
+		if err != nil || offset < 0 {
+			writeFakeError(w, http.StatusBadRequest, "PARAMETER_ERROR.INVALID", "'"+cursor+"' is not a valid cursor for this request")
+			return
+		}
+	}
+
+	page := []interface{}{}
+	if offset < len(resources) {
+		end := offset + limit
+		if end > len(resources) {
+			end = len(resources)
+		}
+		page = resources[offset:end]
+	}
+
+	document := map[string]interface{}{
+		"data": page,
+		"meta": map[string]interface{}{"paging": map[string]int{"total": len(resources), "limit": limit}},
+	}
+	if offset+limit < len(resources) {
+		next := url.Values{}
+		for key, values := range query {
+			next[key] = values
+		}
+		next.Set("cursor", base64.StdEncoding.EncodeToString([]byte(strconv.Itoa(offset+limit))))
+		document["links"] = map[string]string{"next": appleAPIURL + strings.TrimPrefix(r.URL.Path, "/v1") + "?" + next.Encode()}
+	}
+	writeFakeJSON(w, http.StatusOK, document)
+}
+
+func writeFakeError(w http.ResponseWriter, status int, code, detail string) {
+	writeFakeJSON(w, status, map[string]interface{}{
+		"errors": []map[string]string{{
+			"status": strconv.Itoa(status),
+			"code":   code,
+			"title":  http.StatusText(status),
+			"detail": detail,
+		}},
+	})
+}
+
+func writeFakeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		panic(err)
+	}
+}
+
+func decodeFakeBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeFakeError(w, http.StatusUnprocessableEntity, "ENTITY_UNPROCESSABLE", err.Error())
+		return false
+	}
+	return true
+}
+
+func matchesFilter(query url.Values, key, value string) bool {
+	filter := query.Get(key)
+	if filter == "" {
+		return true
+	}
+	for _, v := range strings.Split(filter, ",") {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// newFakeProfileContent wraps the profile plist into a PKCS#7 signed data structure without signers,
+// which is enough for parsing the profile.
+func newFakeProfileContent(name, uuid, teamID string, expiry time.Time, entitlements map[string]interface{}, certificates [][]byte, udids []string) ([]byte, error) {
+	plist := &strings.Builder{}
+	plist.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+`)
+	fmt.Fprintf(plist, "<key>Name</key><string>%s</string>\n", name)
+	fmt.Fprintf(plist, "<key>UUID</key><string>%s</string>\n", uuid)
+	fmt.Fprintf(plist, "<key>TeamName</key><string>Bitrise</string>\n")
+	fmt.Fprintf(plist, "<key>TeamIdentifier</key><array><string>%s</string></array>\n", teamID)
+	fmt.Fprintf(plist, "<key>ApplicationIdentifierPrefix</key><array><string>%s</string></array>\n", teamID)
+	fmt.Fprintf(plist, "<key>Platform</key><array><string>iOS</string></array>\n")
+	fmt.Fprintf(plist, "<key>CreationDate</key><date>%s</date>\n", time.Now().UTC().Format(time.RFC3339))
+	fmt.Fprintf(plist, "<key>ExpirationDate</key><date>%s</date>\n", expiry.UTC().Format(time.RFC3339))
+
+	plist.WriteString("<key>Entitlements</key><dict>\n")
+	for key, value := range entitlements {
+		switch v := value.(type) {
+		case bool:
+			fmt.Fprintf(plist, "<key>%s</key><%t/>\n", key, v)
+		case string:
+			fmt.Fprintf(plist, "<key>%s</key><string>%s</string>\n", key, v)
+		default:
+			return nil, fmt.Errorf("unsupported entitlement value (%s): %v", key, value)
+		}
+	}
+	plist.WriteString("</dict>\n")
+
+	plist.WriteString("<key>DeveloperCertificates</key><array>\n")
+	for _, cert := range certificates {
+		fmt.Fprintf(plist, "<data>%s</data>\n", base64.StdEncoding.EncodeToString(cert))
+	}
+	plist.WriteString("</array>\n")
+
+	if len(udids) > 0 {
+		plist.WriteString("<key>ProvisionedDevices</key><array>\n")
+		for _, udid := range udids {
+			fmt.Fprintf(plist, "<string>%s</string>\n", udid)
+		}
+		plist.WriteString("</array>\n")
+	}
+	plist.WriteString("</dict>\n</plist>\n")
+
+	// The [0] EXPLICIT content is built manually, as a raw value is marshalled without its field tag
+	type contentInfo struct {
+		ContentType asn1.ObjectIdentifier
+		Content     asn1.RawValue
+	}
+	type signedData struct {
+		Version                    int
+		DigestAlgorithmIdentifiers []pkix.AlgorithmIdentifier `asn1:"set"`
+		ContentInfo                contentInfo
+		SignerInfos                []asn1.RawValue `asn1:"set"`
+	}
+
+	data, err := asn1.Marshal([]byte(plist.String()))
+	if err != nil {
+		return nil, err
+	}
+	signed, err := asn1.Marshal(signedData{
+		Version:                    1,
+		DigestAlgorithmIdentifiers: []pkix.AlgorithmIdentifier{},
+		ContentInfo: contentInfo{
+			ContentType: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1},
+			Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: data},
+		},
+		SignerInfos: []asn1.RawValue{},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(contentInfo{
+		ContentType: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2},
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: signed},
+	})
+}
+
+// writeFakeAPIKey writes an App Store Connect API private key (.p8), for signing the JWT of the requests.
+func writeFakeAPIKey(t *testing.T) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.NoError(t, err)
+
+	pth := filepath.Join(t.TempDir(), "AuthKey_FAKEKEYID.p8")
+	assert.NoError(t, ioutil.WriteFile(pth, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600))
+	return pth
+}
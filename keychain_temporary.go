@@ -0,0 +1,167 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitrise-io/go-steputils/v2/stepconf"
+	"github.com/bitrise-io/go-utils/errorutil"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/v2/command"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/keychain"
+)
+
+// temporaryKeychainState is written next to the temporary keychain,
+// so that the cleanup mode can restore the user's keychain search list and default keychain.
+type temporaryKeychainState struct {
+	SearchList      []string `json:"search_list"`
+	DefaultKeychain string   `json:"default_keychain"`
+}
+
+func temporaryKeychainStatePath(keychainPath string) string {
+	return keychainPath + ".state.json"
+}
+
+// newTemporaryKeychain creates a keychain with a random password in a new temporary directory,
+// and puts it first in the user's keychain search list.
+// The original search list and default keychain are saved for removeTemporaryKeychain.
+func newTemporaryKeychain(factory command.Factory) (string, *keychain.Keychain, error) {
+	searchList, err := securityKeychainList(factory, "list-keychains", "-d", "user")
+	if err != nil {
+		return "", nil, err
+	}
+	defaultKeychains, err := securityKeychainList(factory, "default-keychain", "-d", "user")
+	if err != nil {
+		return "", nil, err
+	}
+	state := temporaryKeychainState{SearchList: searchList}
+	if len(defaultKeychains) > 0 {
+		state.DefaultKeychain = defaultKeychains[0]
+	}
+
+	password, err := randomPassword()
+	if err != nil {
+		return "", nil, err
+	}
+	dir, err := ioutil.TempDir("", "keychain")
+	if err != nil {
+		return "", nil, err
+	}
+	pth := filepath.Join(dir, "bitrise-codesign.keychain")
+
+	// The state is written first, so that the cleanup removes a partially set up keychain too
+	b, err := json.MarshalIndent(state, "", "\t")
+	if err != nil {
+		return "", nil, err
+	}
+	if err := ioutil.WriteFile(temporaryKeychainStatePath(pth), b, 0600); err != nil {
+		return "", nil, err
+	}
+
+	temporaryKeychain, err := keychain.New(pth, password, factory)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if _, err := runSecurity(factory, append([]string{"list-keychains", "-d", "user", "-s", pth}, withoutPath(searchList, pth)...)...); err != nil {
+		return "", nil, err
+	}
+
+	return pth, temporaryKeychain, nil
+}
+
+// removeTemporaryKeychain restores the saved keychain search list and default keychain, then deletes the temporary keychain.
+// It refuses deleting a keychain, which was not created by newTemporaryKeychain.
+func removeTemporaryKeychain(pth string, factory command.Factory) error {
+	statePth := temporaryKeychainStatePath(pth)
+	b, err := ioutil.ReadFile(statePth)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("keychain (%s) is not a temporary keychain created by the Step", pth)
+	} else if err != nil {
+		return err
+	}
+
+	var state temporaryKeychainState
+	if err := json.Unmarshal(b, &state); err != nil {
+		return fmt.Errorf("failed to parse temporary keychain state (%s): %s", statePth, err)
+	}
+
+	log.Printf("Restoring keychain search list: %s", strings.Join(state.SearchList, ", "))
+	if _, err := runSecurity(factory, append([]string{"list-keychains", "-d", "user", "-s"}, withoutPath(state.SearchList, pth)...)...); err != nil {
+		return err
+	}
+	if state.DefaultKeychain != "" {
+		log.Printf("Restoring default keychain: %s", state.DefaultKeychain)
+		if _, err := runSecurity(factory, "default-keychain", "-d", "user", "-s", state.DefaultKeychain); err != nil {
+			return err
+		}
+	}
+
+	if _, err := runSecurity(factory, "delete-keychain", pth); err != nil {
+		log.Warnf("Failed to delete keychain: %s", err)
+	}
+	if err := os.Remove(statePth); err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Dir(pth)); err != nil {
+		log.Debugf("Failed to remove the temporary keychain directory: %s", err)
+	}
+	return nil
+}
+
+func runSecurity(factory command.Factory, args ...string) (string, error) {
+	cmd := factory.Create("security", args, nil)
+	out, err := cmd.RunAndReturnTrimmedCombinedOutput()
+	if err != nil {
+		if errorutil.IsExitStatusError(err) {
+			return "", fmt.Errorf("%s failed: %s", cmd.PrintableCommandArgs(), out)
+		}
+		return "", fmt.Errorf("%s failed: %s", cmd.PrintableCommandArgs(), err)
+	}
+	return out, nil
+}
+
+// securityKeychainList runs the security command and parses the listed keychain paths.
+func securityKeychainList(factory command.Factory, args ...string) ([]string, error) {
+	out, err := runSecurity(factory, args...)
+	if err != nil {
+		return nil, err
+	}
+	return parseKeychainList(out), nil
+}
+
+// parseKeychainList parses the security command's keychain list output: one quoted path per line.
+func parseKeychainList(out string) []string {
+	var keychains []string
+	for _, line := range strings.Split(out, "\n") {
+		pth := strings.Trim(strings.TrimSpace(line), `"`)
+		if pth != "" {
+			keychains = append(keychains, pth)
+		}
+	}
+	return keychains
+}
+
+func withoutPath(paths []string, pth string) []string {
+	var filtered []string
+	for _, p := range paths {
+		if p != pth && p != pth+"-db" {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered
+}
+
+func randomPassword() (stepconf.Secret, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate keychain password: %s", err)
+	}
+	return stepconf.Secret(hex.EncodeToString(b)), nil
+}
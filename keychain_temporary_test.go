@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bitrise-io/go-utils/v2/command"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSecurityFactory records the security commands and returns the configured output by subcommand.
+type fakeSecurityFactory struct {
+	outputs  map[string]string
+	commands []string
+}
+
+func (f *fakeSecurityFactory) Create(name string, args []string, _ *command.Opts) command.Command {
+	f.commands = append(f.commands, strings.Join(append([]string{name}, args...), " "))
+	out := ""
+	if len(args) > 0 {
+		out = f.outputs[args[0]]
+	}
+	return fakeCommand{out: out}
+}
+
+type fakeCommand struct {
+	command.Command
+
+	out string
+}
+
+func (c fakeCommand) PrintableCommandArgs() string {
+	return "security"
+}
+
+func (c fakeCommand) RunAndReturnTrimmedCombinedOutput() (string, error) {
+	return c.out, nil
+}
+
+func Test_parseKeychainList(t *testing.T) {
+	out := `    "/Users/vagrant/Library/Keychains/login.keychain-db"
+    "/Library/Keychains/System.keychain"
+`
+	assert.Equal(t, []string{"/Users/vagrant/Library/Keychains/login.keychain-db", "/Library/Keychains/System.keychain"}, parseKeychainList(out))
+	assert.Empty(t, parseKeychainList(""))
+}
+
+func TestTemporaryKeychain(t *testing.T) {
+	factory := &fakeSecurityFactory{outputs: map[string]string{
+		"list-keychains":   `"/Users/vagrant/Library/Keychains/login.keychain-db"`,
+		"default-keychain": `"/Users/vagrant/Library/Keychains/login.keychain-db"`,
+	}}
+
+	pth, _, err := newTemporaryKeychain(factory)
+	assert.NoError(t, err)
+	assert.FileExists(t, temporaryKeychainStatePath(pth))
+	assert.Contains(t, factory.commands, "security list-keychains -d user -s "+pth+" /Users/vagrant/Library/Keychains/login.keychain-db")
+
+	factory.commands = nil
+	assert.NoError(t, removeTemporaryKeychain(pth, factory))
+	assert.Equal(t, []string{
+		"security list-keychains -d user -s /Users/vagrant/Library/Keychains/login.keychain-db",
+		"security default-keychain -d user -s /Users/vagrant/Library/Keychains/login.keychain-db",
+		"security delete-keychain " + pth,
+	}, factory.commands)
+	_, err = os.Stat(filepath.Dir(pth))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestRemoveTemporaryKeychain_NotTemporary(t *testing.T) {
+	factory := &fakeSecurityFactory{}
+	pth := filepath.Join(t.TempDir(), "login.keychain")
+
+	err := removeTemporaryKeychain(pth, factory)
+	assert.EqualError(t, err, "keychain ("+pth+") is not a temporary keychain created by the Step")
+	assert.Empty(t, factory.commands)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/bitrise-io/go-steputils/v2/stepconf"
+	"github.com/bitrise-io/go-xcode/certificateutil"
+)
+
+// Keychain backends
+const (
+	KeychainBackend          = "keychain"
+	TemporaryKeychainBackend = "temporary-keychain"
+	P12DirBackend            = "p12-dir"
+)
+
+// Keychain stores the code signing identities.
+// It is implemented by the macOS keychain (keychain.Keychain) and by the file-based p12Keystore.
+type Keychain interface {
+	InstallCertificate(cert certificateutil.CertificateInfoModel, pass stepconf.Secret) error
+}
+
+// p12Keystore writes every identity into a password protected p12 file of the directory, instead of a macOS keychain.
+// It does not depend on the security tool, so the code signing assets can be ensured on Linux,
+// and the p12 files can be installed later on the macOS build machine.
+type p12Keystore struct {
+	dir      string
+	password stepconf.Secret
+}
+
+func newP12Keystore(dir string, password stepconf.Secret) *p12Keystore {
+	return &p12Keystore{
+		dir:      dir,
+		password: password,
+	}
+}
+
+// InstallCertificate writes the identity into the directory, named by the certificate's SHA1 fingerprint,
+// the p12 file is protected with the keystore's password instead of the given one.
+func (k *p12Keystore) InstallCertificate(cert certificateutil.CertificateInfoModel, _ stepconf.Secret) error {
+	b, err := cert.EncodeToP12(string(k.password))
+	if err != nil {
+		return fmt.Errorf("failed to export certificate (%s): %s", cert.CommonName, err)
+	}
+
+	if err := os.MkdirAll(k.dir, 0700); err != nil {
+		return fmt.Errorf("failed to create directory (%s) for certificates: %s", k.dir, err)
+	}
+
+	pth := filepath.Join(k.dir, cert.SHA1Fingerprint+".p12")
+	return ioutil.WriteFile(pth, b, 0600)
+}
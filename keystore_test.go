@@ -0,0 +1,31 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/go-xcode/certificateutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestIdentity(t *testing.T, commonName string) certificateutil.CertificateInfoModel {
+	cert, key, err := certificateutil.GenerateTestCertificate(1, "TEAMID", "Bitrise", commonName, time.Now().AddDate(1, 0, 0))
+	assert.NoError(t, err)
+	return certificateutil.NewCertificateInfo(*cert, key)
+}
+
+func TestP12Keystore_InstallCertificate(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "identities")
+	identity := newTestIdentity(t, "Apple Development: Bitrise Bot")
+
+	keystore := newP12Keystore(dir, "keystore-password")
+	assert.NoError(t, keystore.InstallCertificate(identity, ""))
+
+	certs, err := certificateutil.CertificatesFromPKCS12File(filepath.Join(dir, identity.SHA1Fingerprint+".p12"), "keystore-password")
+	assert.NoError(t, err)
+	if assert.Len(t, certs, 1) {
+		assert.Equal(t, identity.CommonName, certs[0].CommonName)
+		assert.Equal(t, identity.SHA1Fingerprint, certs[0].SHA1Fingerprint)
+	}
+}
@@ -0,0 +1,479 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-xcode/certificateutil"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/devportalclient/appstoreconnect"
+)
+
+// MacCatalyst is the platform of the Mac variant of an iOS app
+const MacCatalyst autocodesign.Platform = "macCatalyst"
+
+// DeveloperID is the distribution type of the Mac apps distributed outside of the Mac App Store
+const DeveloperID autocodesign.DistributionType = "developer-id"
+
+// Mac Catalyst profile types
+const (
+	MacCatalystAppDevelopment appstoreconnect.ProfileType = "MAC_CATALYST_APP_DEVELOPMENT"
+	MacCatalystAppStore       appstoreconnect.ProfileType = "MAC_CATALYST_APP_STORE"
+	MacCatalystAppDirect      appstoreconnect.ProfileType = "MAC_CATALYST_APP_DIRECT"
+)
+
+// macCatalystBundleIDPrefix is prepended to the bundle IDs of the Mac variant,
+// if the project derives them (DERIVE_MACCATALYST_PRODUCT_BUNDLE_IDENTIFIER = YES).
+const macCatalystBundleIDPrefix = "maccatalyst."
+
+// Certificate common name prefixes of the Mac only certificates
+var (
+	macInstallerCertificatePrefixes           = []string{"3rd Party Mac Developer Installer", "Mac Installer Distribution"}
+	macOnlyCertificatePrefixes                = []string{"3rd Party Mac Developer Application", "Mac App Distribution"}
+	developerIDApplicationCertificatePrefixes = []string{"Developer ID Application"}
+	developerIDCertificatePrefixes            = []string{"Developer ID"}
+	distributionCertificatePrefixes           = []string{"iPhone Distribution", "Apple Distribution"}
+)
+
+// certificateTypes are the certificate types of the distribution types.
+// autocodesign.CertificateTypeByDistribution only knows the iOS distribution types.
+var certificateTypes = map[autocodesign.DistributionType]appstoreconnect.CertificateType{
+	autocodesign.Development: appstoreconnect.IOSDevelopment,
+	autocodesign.AppStore:    appstoreconnect.IOSDistribution,
+	autocodesign.AdHoc:       appstoreconnect.IOSDistribution,
+	autocodesign.Enterprise:  appstoreconnect.IOSDistribution,
+	DeveloperID:              appstoreconnect.DeveloperIDApplication,
+}
+
+// installerCertificateTypes are the certificate types of the Mac installer certificates, used for exporting the Mac packages.
+// The Developer ID exports are signed apps, not installer packages.
+var installerCertificateTypes = map[autocodesign.DistributionType]appstoreconnect.CertificateType{
+	autocodesign.AppStore: appstoreconnect.MacInstallerDistribution,
+}
+
+// macProfileTypes are the profile types of the Mac platforms by distribution type.
+// autocodesign only knows the iOS and tvOS profile types, the Mac app layouts are signed by macCodesignAssetManager.
+var macProfileTypes = map[autocodesign.Platform]map[autocodesign.DistributionType]appstoreconnect.ProfileType{
+	autocodesign.MacOS: {
+		autocodesign.Development: appstoreconnect.MacAppDevelopment,
+		autocodesign.AppStore:    appstoreconnect.MacAppStore,
+		DeveloperID:              appstoreconnect.MacAppDirect,
+	},
+	MacCatalyst: {
+		autocodesign.Development: MacCatalystAppDevelopment,
+		autocodesign.AppStore:    MacCatalystAppStore,
+		DeveloperID:              MacCatalystAppDirect,
+	},
+}
+
+// profileTypePlatform returns the platform of the Mac, iOS and tvOS profile types.
+func profileTypePlatform(profileType appstoreconnect.ProfileType) autocodesign.Platform {
+	for platform, profileTypes := range macProfileTypes {
+		for _, macProfileType := range profileTypes {
+			if macProfileType == profileType {
+				return platform
+			}
+		}
+	}
+	return autocodesign.ProfileTypeToPlatform[profileType]
+}
+
+// profileTypeDistribution returns the distribution type of the Mac, iOS and tvOS profile types.
+func profileTypeDistribution(profileType appstoreconnect.ProfileType) autocodesign.DistributionType {
+	for _, profileTypes := range macProfileTypes {
+		for distribution, macProfileType := range profileTypes {
+			if macProfileType == profileType {
+				return distribution
+			}
+		}
+	}
+	return autocodesign.ProfileTypeToDistribution[profileType]
+}
+
+// readableProfileType falls back to the distribution type for the profile types unknown to appstoreconnect.
+func readableProfileType(profileType appstoreconnect.ProfileType) string {
+	if readable := profileType.ReadableString(); readable != "" {
+		return readable
+	}
+	return string(profileTypeDistribution(profileType))
+}
+
+func isMacPlatform(platform autocodesign.Platform) bool {
+	return platform == autocodesign.MacOS || platform == MacCatalyst
+}
+
+func isMacDevelopmentProfileType(profileType appstoreconnect.ProfileType) bool {
+	return profileType == appstoreconnect.MacAppDevelopment || profileType == MacCatalystAppDevelopment
+}
+
+// validateDistribution returns an error if the distribution type has no profile type for the signed platforms.
+// Developer ID distribution is only supported for macOS projects: the iOS app of a Mac Catalyst project has no Developer ID profile.
+func validateDistribution(distribution autocodesign.DistributionType, platform autocodesign.Platform, macCatalyst bool) error {
+	if distribution == DeveloperID && platform != autocodesign.MacOS {
+		return fmt.Errorf("the %s distribution type is only supported for macOS projects, platform: %s", distribution, platform)
+	}
+
+	if !isMacPlatform(platform) && !macCatalyst {
+		return nil
+	}
+	if _, ok := macProfileTypes[autocodesign.MacOS][distribution]; !ok {
+		return fmt.Errorf("the %s distribution type is not supported for macOS and Mac Catalyst, supported: %s, %s, %s", distribution, autocodesign.Development, autocodesign.AppStore, DeveloperID)
+	}
+	return nil
+}
+
+// localCertificatesByType returns the valid local certificates by type, like autocodesign.GetValidLocalCertificates,
+// but the Developer ID Application certificates are listed by their own type, instead of the development one.
+func localCertificatesByType(certs []certificateutil.CertificateInfoModel) (map[appstoreconnect.CertificateType][]certificateutil.CertificateInfoModel, error) {
+	certsByType, err := autocodesign.GetValidLocalCertificates(certs)
+	if err != nil {
+		return nil, err
+	}
+
+	var developmentCerts []certificateutil.CertificateInfoModel
+	for _, cert := range certsByType[appstoreconnect.IOSDevelopment] {
+		if hasCommonNamePrefix(cert, developerIDApplicationCertificatePrefixes) {
+			certsByType[appstoreconnect.DeveloperIDApplication] = append(certsByType[appstoreconnect.DeveloperIDApplication], cert)
+		} else {
+			developmentCerts = append(developmentCerts, cert)
+		}
+	}
+	certsByType[appstoreconnect.IOSDevelopment] = developmentCerts
+
+	return certsByType, nil
+}
+
+// macCatalystAppLayout returns the app layout of the Mac variant of an iOS app.
+// UITest targets are not signed for Mac Catalyst.
+func macCatalystAppLayout(appLayout autocodesign.AppLayout, derivedBundleIDs bool) autocodesign.AppLayout {
+	entitlementsByBundleID := map[string]autocodesign.Entitlements{}
+	for bundleID, entitlements := range appLayout.EntitlementsByArchivableTargetBundleID {
+		if derivedBundleIDs {
+			bundleID = macCatalystBundleIDPrefix + bundleID
+		}
+		entitlementsByBundleID[bundleID] = entitlements
+	}
+
+	return autocodesign.AppLayout{
+		Platform:                               MacCatalyst,
+		EntitlementsByArchivableTargetBundleID: entitlementsByBundleID,
+	}
+}
+
+// macCodesignAssetManager ensures the code signing assets of a macOS or Mac Catalyst app layout.
+// autocodesign only knows the iOS and tvOS profile types, so the app layout is passed to it as an iOS one:
+// macProfileDevPortalClient translates the iOS profiles to the Mac ones,
+// and macLocalCodeSignAssetManager looks up the local profiles of the Mac platform.
+// The Developer ID assets are passed to autocodesign as App Store ones, signed by the Developer ID Application certificates.
+type macCodesignAssetManager struct {
+	autocodesign.CodesignAssetManager
+
+	developerIDCertificates *developerIDCertificateProvider
+}
+
+func newMacCodesignAssetManager(platform autocodesign.Platform, distribution autocodesign.DistributionType, devPortalClient autocodesign.DevPortalClient, certificateProvider autocodesign.CertificateProvider, assetWriter autocodesign.AssetWriter, localCodeSignAssetManager autocodesign.LocalCodeSignAssetManager) macCodesignAssetManager {
+	// The iOS devices are hidden from autocodesign, macProfileDevPortalClient adds the Mac devices to the development profiles
+	devPortalClient = newDeviceFilteringDevPortalClient(devPortalClient, DeviceFilter{Classes: []appstoreconnect.DeviceClass{appstoreconnect.Mac}})
+
+	var developerIDCertificates *developerIDCertificateProvider
+	if distribution == DeveloperID {
+		developerIDCertificates = newDeveloperIDCertificateProvider(certificateProvider)
+		certificateProvider = developerIDCertificates
+	}
+
+	return macCodesignAssetManager{
+		CodesignAssetManager: autocodesign.NewCodesignAssetManager(
+			newMacProfileDevPortalClient(devPortalClient, platform, distribution),
+			certificateProvider,
+			assetWriter,
+			macLocalCodeSignAssetManager{LocalCodeSignAssetManager: localCodeSignAssetManager, platform: platform, distribution: distribution},
+		),
+		developerIDCertificates: developerIDCertificates,
+	}
+}
+
+// EnsureCodesignAssets ...
+func (m macCodesignAssetManager) EnsureCodesignAssets(appLayout autocodesign.AppLayout, opts autocodesign.CodesignAssetsOpts) (map[autocodesign.DistributionType]autocodesign.AppCodesignAssets, error) {
+	appLayout.Platform = autocodesign.IOS
+	if m.developerIDCertificates == nil {
+		return m.CodesignAssetManager.EnsureCodesignAssets(appLayout, opts)
+	}
+
+	opts.DistributionType = autocodesign.AppStore
+	codesignAssetsByDistributionType, err := m.CodesignAssetManager.EnsureCodesignAssets(appLayout, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if assets, ok := codesignAssetsByDistributionType[autocodesign.AppStore]; ok {
+		assets.Certificate = m.developerIDCertificates.certificate(assets.Certificate)
+		codesignAssetsByDistributionType[DeveloperID] = assets
+		delete(codesignAssetsByDistributionType, autocodesign.AppStore)
+	}
+	return codesignAssetsByDistributionType, nil
+}
+
+// macLocalCodeSignAssetManager looks up the local profiles of the Mac platform,
+// for the app layout passed to autocodesign as an iOS one.
+type macLocalCodeSignAssetManager struct {
+	autocodesign.LocalCodeSignAssetManager
+
+	platform     autocodesign.Platform
+	distribution autocodesign.DistributionType
+}
+
+// FindCodesignAssets ...
+func (m macLocalCodeSignAssetManager) FindCodesignAssets(appLayout autocodesign.AppLayout, distrType autocodesign.DistributionType, certsByType map[appstoreconnect.CertificateType][]autocodesign.Certificate, deviceIDs []string, minProfileDaysValid int) (*autocodesign.AppCodesignAssets, *autocodesign.AppLayout, error) {
+	appLayout.Platform = m.platform
+	if distrType == autocodesign.AppStore && m.distribution == DeveloperID {
+		// The Developer ID certificates are listed where the local lookup looks up the certificates of the Developer ID profiles
+		distrType = DeveloperID
+		certsByType = map[appstoreconnect.CertificateType][]autocodesign.Certificate{
+			autocodesign.CertificateTypeByDistribution[DeveloperID]: certsByType[appstoreconnect.IOSDistribution],
+		}
+	}
+	assets, missingAppLayout, err := m.LocalCodeSignAssetManager.FindCodesignAssets(appLayout, distrType, certsByType, deviceIDs, minProfileDaysValid)
+	if missingAppLayout != nil {
+		missingAppLayout.Platform = autocodesign.IOS
+	}
+	return assets, missingAppLayout, err
+}
+
+// macProfileDevPortalClient translates the iOS profile types and names, autocodesign uses for the Mac app layouts,
+// to the profile types of the Mac platform and the `Bitrise <platform> <distribution type> - (<bundle id>)` names.
+// The App Store profiles are translated to Developer ID ones, if the distribution type is Developer ID.
+// It adds the Mac devices to the Mac development profiles (autocodesign only collects iOS and tvOS devices).
+type macProfileDevPortalClient struct {
+	autocodesign.DevPortalClient
+
+	platform     autocodesign.Platform
+	distribution autocodesign.DistributionType
+}
+
+func newMacProfileDevPortalClient(devPortalClient autocodesign.DevPortalClient, platform autocodesign.Platform, distribution autocodesign.DistributionType) *macProfileDevPortalClient {
+	return &macProfileDevPortalClient{
+		DevPortalClient: devPortalClient,
+		platform:        platform,
+		distribution:    distribution,
+	}
+}
+
+// FindProfile ...
+func (c *macProfileDevPortalClient) FindProfile(name string, profileType appstoreconnect.ProfileType) (autocodesign.Profile, error) {
+	name, profileType, err := c.macProfile(name, profileType)
+	if err != nil {
+		return nil, err
+	}
+	return c.DevPortalClient.FindProfile(name, profileType)
+}
+
+// CreateProfile ...
+func (c *macProfileDevPortalClient) CreateProfile(name string, profileType appstoreconnect.ProfileType, bundleID appstoreconnect.BundleID, certificateIDs []string, deviceIDs []string) (autocodesign.Profile, error) {
+	name, profileType, err := c.macProfile(name, profileType)
+	if err != nil {
+		return nil, err
+	}
+
+	if isMacDevelopmentProfileType(profileType) {
+		devices, err := c.DevPortalClient.ListDevices("", appstoreconnect.MacOSDevice)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list Mac devices: %s", err)
+		}
+
+		for _, device := range devices {
+			if device.Attributes.DeviceClass == appstoreconnect.Mac {
+				deviceIDs = append(deviceIDs, device.ID)
+			}
+		}
+	}
+
+	return c.DevPortalClient.CreateProfile(name, profileType, bundleID, certificateIDs, deviceIDs)
+}
+
+func (c *macProfileDevPortalClient) macProfile(name string, profileType appstoreconnect.ProfileType) (string, appstoreconnect.ProfileType, error) {
+	distribution := autocodesign.ProfileTypeToDistribution[profileType]
+	macDistribution := distribution
+	if distribution == autocodesign.AppStore && c.distribution == DeveloperID {
+		macDistribution = DeveloperID
+	}
+
+	macProfileType, ok := macProfileTypes[c.platform][macDistribution]
+	if !ok {
+		return "", "", fmt.Errorf("no %s profile type for the %s distribution type", c.platform, macDistribution)
+	}
+
+	iosName := fmt.Sprintf("Bitrise %s %s ", autocodesign.IOS, distribution)
+	macName := fmt.Sprintf("Bitrise %s %s ", c.platform, macDistribution)
+	return strings.Replace(name, iosName, macName, 1), macProfileType, nil
+}
+
+// appIDPlatforms returns the app ID platforms of the app layouts' bundle IDs, including the UITest targets' wildcard bundle IDs.
+// The app layouts are listed in signing order, an app ID shared by more app layouts is created by the first one.
+func appIDPlatforms(appLayouts ...autocodesign.AppLayout) map[string]appstoreconnect.BundleIDPlatform {
+	platforms := map[string]appstoreconnect.BundleIDPlatform{}
+	for _, appLayout := range appLayouts {
+		platform := appstoreconnect.IOS
+		if isMacPlatform(appLayout.Platform) {
+			platform = appstoreconnect.MacOS
+		}
+
+		bundleIDs := bundleIDsOfAppLayout(appLayout)
+		for _, bundleID := range appLayout.UITestTargetBundleIDs {
+			if wildcardBundleID, err := autocodesign.CreateWildcardBundleID(bundleID); err == nil {
+				bundleIDs = append(bundleIDs, wildcardBundleID)
+			}
+		}
+
+		for _, bundleID := range bundleIDs {
+			if _, ok := platforms[bundleID]; !ok {
+				platforms[bundleID] = platform
+			}
+		}
+	}
+	return platforms
+}
+
+// appIDPlatformDevPortalClient creates the app IDs with the platform of their app layout (autocodesign always uses iOS).
+// It wraps the Developer Portal client directly, so that every other client, like the dry-run one, wraps the app ID creation.
+type appIDPlatformDevPortalClient struct {
+	autocodesign.DevPortalClient
+
+	client    *appstoreconnect.Client
+	platforms map[string]appstoreconnect.BundleIDPlatform
+}
+
+func newAppIDPlatformDevPortalClient(devPortalClient autocodesign.DevPortalClient, client *appstoreconnect.Client, platforms map[string]appstoreconnect.BundleIDPlatform) *appIDPlatformDevPortalClient {
+	return &appIDPlatformDevPortalClient{
+		DevPortalClient: devPortalClient,
+		client:          client,
+		platforms:       platforms,
+	}
+}
+
+// CreateBundleID ...
+func (c *appIDPlatformDevPortalClient) CreateBundleID(bundleIDIdentifier, appIDName string) (*appstoreconnect.BundleID, error) {
+	platform, ok := c.platforms[bundleIDIdentifier]
+	if !ok || platform == appstoreconnect.IOS {
+		return c.DevPortalClient.CreateBundleID(bundleIDIdentifier, appIDName)
+	}
+
+	r, err := c.client.Provisioning.CreateBundleID(
+		appstoreconnect.BundleIDCreateRequest{
+			Data: appstoreconnect.BundleIDCreateRequestData{
+				Attributes: appstoreconnect.BundleIDCreateRequestDataAttributes{
+					Identifier: bundleIDIdentifier,
+					Name:       appIDName,
+					Platform:   platform,
+				},
+				Type: "bundleIds",
+			},
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to register AppID for bundleID (%s): %s", bundleIDIdentifier, err)
+	}
+
+	return &r.Data, nil
+}
+
+// macCertificateProvider holds back the Mac only certificates, which autocodesign would take for development certificates.
+// The Mac installer certificates are kept for exporting Mac App Store packages.
+// The Developer ID Application certificates replace the distribution certificates, if the distribution type is Developer ID.
+type macCertificateProvider struct {
+	autocodesign.CertificateProvider
+
+	distribution          autocodesign.DistributionType
+	installerCertificates []certificateutil.CertificateInfoModel
+}
+
+func newMacCertificateProvider(provider autocodesign.CertificateProvider, distribution autocodesign.DistributionType) *macCertificateProvider {
+	return &macCertificateProvider{CertificateProvider: provider, distribution: distribution}
+}
+
+// GetCertificates ...
+func (p *macCertificateProvider) GetCertificates() ([]certificateutil.CertificateInfoModel, error) {
+	certs, err := p.CertificateProvider.GetCertificates()
+	if err != nil {
+		return nil, err
+	}
+
+	var signingCerts []certificateutil.CertificateInfoModel
+	p.installerCertificates = nil
+	for _, cert := range certs {
+		switch {
+		case hasCommonNamePrefix(cert, macInstallerCertificatePrefixes):
+			p.installerCertificates = append(p.installerCertificates, cert)
+		case p.distribution == DeveloperID && hasCommonNamePrefix(cert, developerIDApplicationCertificatePrefixes):
+			signingCerts = append(signingCerts, cert)
+		case hasCommonNamePrefix(cert, developerIDCertificatePrefixes):
+			log.Warnf("Ignoring Developer ID certificate, it is only used by the %s distribution type: %s", DeveloperID, cert)
+		case p.distribution == DeveloperID && hasCommonNamePrefix(cert, distributionCertificatePrefixes):
+			log.Warnf("Ignoring distribution certificate, the %s distribution type uses the Developer ID Application certificates: %s", DeveloperID, cert)
+		case hasCommonNamePrefix(cert, macOnlyCertificatePrefixes):
+			log.Warnf("Ignoring certificate not supported by automatic code signing: %s, use an Apple Development or Apple Distribution certificate instead", cert)
+		default:
+			signingCerts = append(signingCerts, cert)
+		}
+	}
+
+	return signingCerts, nil
+}
+
+// InstallerCertificate returns the first valid Mac installer certificate, if any.
+func (p *macCertificateProvider) InstallerCertificate() *certificateutil.CertificateInfoModel {
+	valid := certificateutil.FilterValidCertificateInfos(p.installerCertificates).ValidCertificates
+	if len(valid) == 0 {
+		return nil
+	}
+	return &valid[0]
+}
+
+// developerIDCertificateProvider presents the Developer ID Application certificates as Apple Distribution ones,
+// so that autocodesign ensures the Developer ID assets as App Store ones.
+type developerIDCertificateProvider struct {
+	autocodesign.CertificateProvider
+
+	certificates map[string]certificateutil.CertificateInfoModel
+}
+
+func newDeveloperIDCertificateProvider(provider autocodesign.CertificateProvider) *developerIDCertificateProvider {
+	return &developerIDCertificateProvider{CertificateProvider: provider}
+}
+
+// GetCertificates ...
+func (p *developerIDCertificateProvider) GetCertificates() ([]certificateutil.CertificateInfoModel, error) {
+	certs, err := p.CertificateProvider.GetCertificates()
+	if err != nil {
+		return nil, err
+	}
+
+	p.certificates = map[string]certificateutil.CertificateInfoModel{}
+	var presentedCerts []certificateutil.CertificateInfoModel
+	for _, cert := range certs {
+		if hasCommonNamePrefix(cert, developerIDApplicationCertificatePrefixes) {
+			p.certificates[cert.SHA1Fingerprint] = cert
+			cert.CommonName = "Apple Distribution" + cert.CommonName[len(developerIDApplicationCertificatePrefixes[0]):]
+		}
+		presentedCerts = append(presentedCerts, cert)
+	}
+
+	return presentedCerts, nil
+}
+
+// certificate returns the Developer ID Application certificate of a presented certificate.
+func (p *developerIDCertificateProvider) certificate(cert certificateutil.CertificateInfoModel) certificateutil.CertificateInfoModel {
+	if original, ok := p.certificates[cert.SHA1Fingerprint]; ok {
+		return original
+	}
+	return cert
+}
+
+func hasCommonNamePrefix(cert certificateutil.CertificateInfoModel, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(strings.ToLower(cert.CommonName), strings.ToLower(prefix)) {
+			return true
+		}
+	}
+	return false
+}
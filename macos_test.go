@@ -0,0 +1,242 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/bitrise-io/go-xcode/certificateutil"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/devportalclient/appstoreconnect"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/localcodesignasset"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_profileTypePlatform(t *testing.T) {
+	assert.Equal(t, autocodesign.MacOS, profileTypePlatform(appstoreconnect.MacAppStore))
+	assert.Equal(t, MacCatalyst, profileTypePlatform(MacCatalystAppStore))
+	assert.Equal(t, autocodesign.TVOS, profileTypePlatform(appstoreconnect.TvOSAppAdHoc))
+	assert.Equal(t, autocodesign.AppStore, profileTypeDistribution(MacCatalystAppStore))
+	assert.Equal(t, autocodesign.Enterprise, profileTypeDistribution(appstoreconnect.IOSAppInHouse))
+	assert.Equal(t, "app-store", readableProfileType(MacCatalystAppStore))
+	assert.Equal(t, MacCatalyst, profileTypePlatform(MacCatalystAppDirect))
+	assert.Equal(t, DeveloperID, profileTypeDistribution(appstoreconnect.MacAppDirect))
+
+	// the autocodesign lookup tables are left untouched
+	_, ok := autocodesign.PlatformToProfileTypeByDistribution[autocodesign.MacOS]
+	assert.False(t, ok)
+	_, ok = autocodesign.ProfileTypeToPlatform[MacCatalystAppStore]
+	assert.False(t, ok)
+}
+
+func Test_macCatalystAppLayout(t *testing.T) {
+	appLayout := autocodesign.AppLayout{
+		Platform: autocodesign.IOS,
+		EntitlementsByArchivableTargetBundleID: map[string]autocodesign.Entitlements{
+			"io.bitrise.app":           {"aps-environment": "development"},
+			"io.bitrise.app.extension": nil,
+		},
+		UITestTargetBundleIDs: []string{"io.bitrise.app.uitests"},
+	}
+
+	assert.Equal(t, autocodesign.AppLayout{
+		Platform: MacCatalyst,
+		EntitlementsByArchivableTargetBundleID: map[string]autocodesign.Entitlements{
+			"maccatalyst.io.bitrise.app":           {"aps-environment": "development"},
+			"maccatalyst.io.bitrise.app.extension": nil,
+		},
+	}, macCatalystAppLayout(appLayout, true))
+	assert.Equal(t, appLayout.EntitlementsByArchivableTargetBundleID, macCatalystAppLayout(appLayout, false).EntitlementsByArchivableTargetBundleID)
+}
+
+func TestMacProfileDevPortalClient(t *testing.T) {
+	bundleID := appstoreconnect.BundleID{ID: "bundle-id"}
+	developmentName := "Bitrise macCatalyst development - (io.bitrise.app)"
+	appStoreName := "Wildcard Bitrise macCatalyst app-store - (io.bitrise)"
+	created := plannedProfile{attributes: appstoreconnect.ProfileAttributes{Name: developmentName}}
+
+	mockClient := new(autocodesign.MockDevPortalClient)
+	mockClient.On("FindProfile", developmentName, MacCatalystAppDevelopment).Return(nil, nil)
+	mockClient.On("ListDevices", "", appstoreconnect.MacOSDevice).Return([]appstoreconnect.Device{
+		newTestDevice("mac", appstoreconnect.Mac, appstoreconnect.Enabled),
+	}, nil)
+	mockClient.On("CreateProfile", developmentName, MacCatalystAppDevelopment, bundleID, []string{"cert"}, []string{"mac"}).Return(created, nil)
+	mockClient.On("CreateProfile", appStoreName, MacCatalystAppStore, bundleID, []string{"cert"}, []string(nil)).Return(created, nil)
+
+	client := newMacProfileDevPortalClient(mockClient, MacCatalyst, autocodesign.AppStore)
+	profile, err := client.FindProfile("Bitrise iOS development - (io.bitrise.app)", appstoreconnect.IOSAppDevelopment)
+	assert.NoError(t, err)
+	assert.Nil(t, profile)
+	_, err = client.CreateProfile("Bitrise iOS development - (io.bitrise.app)", appstoreconnect.IOSAppDevelopment, bundleID, []string{"cert"}, nil)
+	assert.NoError(t, err)
+	_, err = client.CreateProfile("Wildcard Bitrise iOS app-store - (io.bitrise)", appstoreconnect.IOSAppStore, bundleID, []string{"cert"}, nil)
+	assert.NoError(t, err)
+
+	_, err = client.FindProfile("Bitrise iOS ad-hoc - (io.bitrise.app)", appstoreconnect.IOSAppAdHoc)
+	assert.EqualError(t, err, "no macCatalyst profile type for the ad-hoc distribution type")
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestMacProfileDevPortalClient_DeveloperID(t *testing.T) {
+	bundleID := appstoreconnect.BundleID{ID: "bundle-id"}
+	name := "Bitrise macOS developer-id - (io.bitrise.mac)"
+	created := plannedProfile{attributes: appstoreconnect.ProfileAttributes{Name: name}}
+
+	mockClient := new(autocodesign.MockDevPortalClient)
+	mockClient.On("CreateProfile", name, appstoreconnect.MacAppDirect, bundleID, []string{"cert"}, []string(nil)).Return(created, nil)
+
+	client := newMacProfileDevPortalClient(mockClient, autocodesign.MacOS, DeveloperID)
+	_, err := client.CreateProfile("Bitrise iOS app-store - (io.bitrise.mac)", appstoreconnect.IOSAppStore, bundleID, []string{"cert"}, nil)
+	assert.NoError(t, err)
+
+	mockClient.AssertExpectations(t)
+}
+
+func Test_appIDPlatforms(t *testing.T) {
+	iosAppLayout := autocodesign.AppLayout{
+		Platform:                               autocodesign.IOS,
+		EntitlementsByArchivableTargetBundleID: map[string]autocodesign.Entitlements{"io.bitrise.app": nil},
+	}
+	catalystAppLayout := autocodesign.AppLayout{
+		Platform: MacCatalyst,
+		EntitlementsByArchivableTargetBundleID: map[string]autocodesign.Entitlements{
+			"io.bitrise.app":             nil,
+			"maccatalyst.io.bitrise.app": nil,
+		},
+	}
+	macAppLayout := autocodesign.AppLayout{
+		Platform:                               autocodesign.MacOS,
+		EntitlementsByArchivableTargetBundleID: map[string]autocodesign.Entitlements{"io.bitrise.mac": nil},
+		UITestTargetBundleIDs:                  []string{"io.bitrise.mac.uitests"},
+	}
+
+	assert.Equal(t, map[string]appstoreconnect.BundleIDPlatform{
+		// created by the iOS app layout, signed first
+		"io.bitrise.app":             appstoreconnect.IOS,
+		"maccatalyst.io.bitrise.app": appstoreconnect.MacOS,
+		"io.bitrise.mac":             appstoreconnect.MacOS,
+		"io.bitrise.mac.uitests":     appstoreconnect.MacOS,
+		"io.bitrise.mac.*":           appstoreconnect.MacOS,
+	}, appIDPlatforms(iosAppLayout, catalystAppLayout, macAppLayout))
+}
+
+func TestMacCodesignAssetManager_FakeAppStoreConnect(t *testing.T) {
+	identity := newTestIdentity(t, "Apple Development: Bitrise Bot")
+	appLayout := autocodesign.AppLayout{
+		Platform:                               autocodesign.MacOS,
+		EntitlementsByArchivableTargetBundleID: map[string]autocodesign.Entitlements{"io.bitrise.mac": {}},
+	}
+	opts := autocodesign.CodesignAssetsOpts{DistributionType: autocodesign.Development}
+
+	server := newFakeAppStoreConnect(t)
+	server.AddCertificate(appstoreconnect.IOSDevelopment, identity)
+	server.AddDevice("00008030-000A", appstoreconnect.Iphone)
+	macDeviceID := server.AddDevice("00006000-000B", appstoreconnect.Mac)
+
+	newManager := func(dryRun bool) macCodesignAssetManager {
+		var client autocodesign.DevPortalClient = newAppIDPlatformDevPortalClient(newFakeDevPortalClient(t, server, ""), newFakeAPIClient(t, server), appIDPlatforms(appLayout))
+		if dryRun {
+			client = newDryRunDevPortalClient(client, &PortalPlan{}, appIDPlatforms(appLayout))
+		}
+		certificateProvider := new(autocodesign.MockCertificateProvider)
+		certificateProvider.On("GetCertificates").Return([]certificateutil.CertificateInfoModel{identity}, nil)
+		localManager := localcodesignasset.NewManager(newProfileDirProvider(nil), newProfileDirProvider(nil))
+		return newMacCodesignAssetManager(autocodesign.MacOS, autocodesign.Development, client, certificateProvider, dryRunAssetWriter{}, localManager)
+	}
+
+	// the dry-run does not create the Mac app ID
+	_, err := newManager(true).EnsureCodesignAssets(appLayout, opts)
+	assert.NoError(t, err)
+	assert.Empty(t, server.BundleIDs())
+
+	for i := 0; i < 2; i++ {
+		assets, err := newManager(false).EnsureCodesignAssets(appLayout, opts)
+		assert.NoError(t, err)
+		if profile := assets[autocodesign.Development].ArchivableTargetProfilesByBundleID["io.bitrise.mac"]; assert.NotNil(t, profile) {
+			assert.Equal(t, "Bitrise macOS development - (io.bitrise.mac)", profile.Attributes().Name)
+		}
+	}
+
+	// the second run reuses the profile
+	if profiles := server.Profiles(); assert.Len(t, profiles, 1) {
+		assert.Equal(t, appstoreconnect.MacAppDevelopment, profiles[0].Type)
+		assert.Equal(t, []string{macDeviceID}, profiles[0].DeviceIDs)
+	}
+	if bundleIDs := server.BundleIDs(); assert.Len(t, bundleIDs, 1) {
+		assert.Equal(t, appstoreconnect.MacOS, bundleIDs[0].Platform)
+		assert.Equal(t, "Bitrise io bitrise mac", bundleIDs[0].Name)
+	}
+}
+
+func TestMacCodesignAssetManager_DeveloperID(t *testing.T) {
+	developerID := newTestIdentity(t, "Developer ID Application: Bitrise")
+	appLayout := autocodesign.AppLayout{
+		Platform:                               autocodesign.MacOS,
+		EntitlementsByArchivableTargetBundleID: map[string]autocodesign.Entitlements{"io.bitrise.mac": {}},
+	}
+
+	server := newFakeAppStoreConnect(t)
+	server.AddCertificate(appstoreconnect.DeveloperIDApplication, developerID)
+	server.AddDevice("00006000-000B", appstoreconnect.Mac)
+
+	client := newAppIDPlatformDevPortalClient(newFakeDevPortalClient(t, server, ""), newFakeAPIClient(t, server), appIDPlatforms(appLayout))
+	certificateProvider := new(autocodesign.MockCertificateProvider)
+	certificateProvider.On("GetCertificates").Return([]certificateutil.CertificateInfoModel{developerID}, nil)
+	localManager := localcodesignasset.NewManager(newProfileDirProvider(nil), newProfileDirProvider(nil))
+	manager := newMacCodesignAssetManager(autocodesign.MacOS, DeveloperID, client, newMacCertificateProvider(certificateProvider, DeveloperID), dryRunAssetWriter{}, localManager)
+
+	assets, err := manager.EnsureCodesignAssets(appLayout, autocodesign.CodesignAssetsOpts{DistributionType: DeveloperID})
+	assert.NoError(t, err)
+	assert.NotContains(t, assets, autocodesign.AppStore)
+	if developerIDAssets, ok := assets[DeveloperID]; assert.True(t, ok) {
+		assert.Equal(t, developerID.CommonName, developerIDAssets.Certificate.CommonName)
+		if profile := developerIDAssets.ArchivableTargetProfilesByBundleID["io.bitrise.mac"]; assert.NotNil(t, profile) {
+			assert.Equal(t, "Bitrise macOS developer-id - (io.bitrise.mac)", profile.Attributes().Name)
+		}
+	}
+
+	if profiles := server.Profiles(); assert.Len(t, profiles, 1) {
+		assert.Equal(t, appstoreconnect.MacAppDirect, profiles[0].Type)
+		assert.Empty(t, profiles[0].DeviceIDs)
+	}
+}
+
+func TestMacCertificateProvider_GetCertificates(t *testing.T) {
+	development := certificateutil.CertificateInfoModel{CommonName: "Apple Development: Bitrise Bot (ABCD1234)"}
+	distribution := certificateutil.CertificateInfoModel{CommonName: "Apple Distribution: Bitrise (TEAM1234)"}
+	installer := certificateutil.CertificateInfoModel{CommonName: "3rd Party Mac Developer Installer: Bitrise (TEAM1234)"}
+	developerID := certificateutil.CertificateInfoModel{CommonName: "Developer ID Application: Bitrise (TEAM1234)"}
+
+	mockProvider := new(autocodesign.MockCertificateProvider)
+	mockProvider.On("GetCertificates").Return([]certificateutil.CertificateInfoModel{development, distribution, installer, developerID}, nil)
+
+	provider := newMacCertificateProvider(mockProvider, autocodesign.AppStore)
+	certs, err := provider.GetCertificates()
+	assert.NoError(t, err)
+	assert.Equal(t, []certificateutil.CertificateInfoModel{development, distribution}, certs)
+	assert.Equal(t, []certificateutil.CertificateInfoModel{installer}, provider.installerCertificates)
+
+	// the Developer ID Application certificates replace the distribution ones
+	provider = newMacCertificateProvider(mockProvider, DeveloperID)
+	certs, err = provider.GetCertificates()
+	assert.NoError(t, err)
+	assert.Equal(t, []certificateutil.CertificateInfoModel{development, developerID}, certs)
+}
+
+func Test_localCertificatesByType(t *testing.T) {
+	development := newTestIdentity(t, "Apple Development: Bitrise Bot")
+	developerID := newTestIdentity(t, "Developer ID Application: Bitrise")
+
+	certsByType, err := localCertificatesByType([]certificateutil.CertificateInfoModel{development, developerID})
+	assert.NoError(t, err)
+	assert.Equal(t, []certificateutil.CertificateInfoModel{development}, certsByType[appstoreconnect.IOSDevelopment])
+	assert.Equal(t, []certificateutil.CertificateInfoModel{developerID}, certsByType[appstoreconnect.DeveloperIDApplication])
+}
+
+func Test_validateDistribution(t *testing.T) {
+	assert.NoError(t, validateDistribution(autocodesign.AdHoc, autocodesign.IOS, false))
+	assert.NoError(t, validateDistribution(autocodesign.Development, autocodesign.MacOS, false))
+	assert.NoError(t, validateDistribution(autocodesign.AppStore, autocodesign.IOS, true))
+	assert.NoError(t, validateDistribution(DeveloperID, autocodesign.MacOS, false))
+	assert.EqualError(t, validateDistribution(autocodesign.AdHoc, autocodesign.MacOS, false), "the ad-hoc distribution type is not supported for macOS and Mac Catalyst, supported: development, app-store, developer-id")
+	assert.EqualError(t, validateDistribution(DeveloperID, autocodesign.IOS, true), "the developer-id distribution type is only supported for macOS projects, platform: iOS")
+}
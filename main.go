@@ -1,8 +1,11 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/bitrise-io/go-steputils/tools"
 	"github.com/bitrise-io/go-steputils/v2/stepconf"
@@ -15,11 +18,10 @@ import (
 	"github.com/bitrise-io/go-xcode/devportalservice"
 	"github.com/bitrise-io/go-xcode/v2/autocodesign"
 	"github.com/bitrise-io/go-xcode/v2/autocodesign/certdownloader"
-	"github.com/bitrise-io/go-xcode/v2/autocodesign/codesignasset"
 	"github.com/bitrise-io/go-xcode/v2/autocodesign/devportalclient"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/devportalclient/appstoreconnect"
 	"github.com/bitrise-io/go-xcode/v2/autocodesign/keychain"
 	"github.com/bitrise-io/go-xcode/v2/autocodesign/localcodesignasset"
-	"github.com/bitrise-io/go-xcode/v2/autocodesign/projectmanager"
 )
 
 func failf(format string, args ...interface{}) {
@@ -40,6 +42,21 @@ func main() {
 	logger.EnableDebugLog(cfg.VerboseLog)
 	v1log.SetEnableDebugLog(cfg.VerboseLog) // for compatibility
 
+	if cfg.Timeout < 0 {
+		failf("Invalid input: timeout should be 0 (no timeout) or a positive number of seconds")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if cfg.Timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, time.Duration(cfg.Timeout)*time.Second)
+		defer cancelTimeout()
+	}
+
+	if err := os.MkdirAll(cfg.OutputDir, 0700); err != nil {
+		failf("Failed to create output directory: %s", err)
+	}
+
 	logger.Warnf(`
 This Step has been deprecated in favour of the new automatic code signing options on Bitrise.
 
@@ -58,87 +75,449 @@ You can read more about these changes in our blog post:
 https://blog.bitrise.io/post/simplifying-automatic-code-signing-on-bitrise
 `)
 
+	if cfg.Mode == CleanupKeychainMode {
+		fmt.Println()
+		logger.Infof("Removing temporary keychain")
+		if err := removeTemporaryKeychain(cfg.KeychainPath, command.NewFactory(env.NewRepository())); err != nil {
+			failf("Failed to remove temporary keychain: %s", err)
+		}
+		logger.Donef("Temporary keychain removed: %s", cfg.KeychainPath)
+		return
+	}
+
 	certsWithPrivateKey, err := cfg.Certificates()
 	if err != nil {
 		failf("Failed to convert certificate URLs: %s", err)
 	}
 
-	authInputs := appleauth.Inputs{
-		APIIssuer:  cfg.APIIssuer,
-		APIKeyPath: string(cfg.APIKeyPath),
-	}
-	if err := authInputs.Validate(); err != nil {
-		failf("Issue with authentication related inputs: %v", err)
-	}
+	distribution := cfg.DistributionType()
 
-	// Analyze project
-	fmt.Println()
-	logger.Infof("Analyzing project")
-	project, err := projectmanager.NewProject(projectmanager.InitParams{
-		ProjectOrWorkspacePath: cfg.ProjectPath,
-		SchemeName:             cfg.Scheme,
-		ConfigurationName:      cfg.Configuration,
-	})
-	if err != nil {
-		failf(err.Error())
-	}
+	// The ensure and offline modes open the project first, the Developer Portal client creates the app IDs of its app layouts
+	var project Project
+	var appLayout TargetAppLayout
+	var signedAppLayouts SignedAppLayouts
+	var usesICloud, signsForMac bool
+	if cfg.Mode == EnsureMode || cfg.Mode == OfflineMode {
+		project, appLayout = openProject(cfg, logger)
 
-	appLayout, err := project.GetAppLayout(cfg.SignUITestTargets)
-	if err != nil {
-		failf(err.Error())
-	}
+		// The local code signing asset lookup removes the already signed targets from the app layout
+		usesICloud, err = usesICloudContainers(appLayout.AppLayout)
+		if err != nil {
+			failf(err.Error())
+		}
 
-	authSources, err := parseAuthSources(cfg.BitriseConnection)
-	if err != nil {
-		failf("Invalid input: unexpected value for Bitrise Apple Developer Connection (%s)", cfg.BitriseConnection)
+		// The watchOS targets are signed in a separate pass, with the Apple Watch devices only
+		signedAppLayouts, err = NewSignedAppLayouts(appLayout, cfg.MacCatalyst, cfg.MacCatalystDerivedBundleIDs)
+		if err != nil {
+			failf(err.Error())
+		}
+		if signedAppLayouts.Watch != nil {
+			logger.Printf("watchOS targets: %s", bundleIDsOfAppLayout(*signedAppLayouts.Watch))
+		}
+
+		signsForMac = isMacPlatform(appLayout.Platform) || cfg.MacCatalyst
+		if err := validateDistribution(distribution, appLayout.Platform, cfg.MacCatalyst); err != nil {
+			failf(err.Error())
+		}
 	}
 
+	// The offline mode leaves the Developer Portal client unset, as it only uses the local code signing assets
 	var connection *devportalservice.AppleDeveloperConnection
-	isRunningOnBitrise := cfg.BuildURL != "" && cfg.BuildAPIToken != ""
-
-	switch {
-	case cfg.BitriseConnection != "off" && !isRunningOnBitrise:
+	var devPortalClient autocodesign.DevPortalClient
+	var apiRateLimiter *rateLimiter
+	var apiClient *appstoreconnect.Client
+	if cfg.Mode == OfflineMode {
 		fmt.Println()
-		logger.Warnf("Connected Apple Developer Portal Account not found. Step is not running on bitrise.io: BITRISE_BUILD_URL and BITRISE_BUILD_API_TOKEN envs are not set")
-	case cfg.BitriseConnection != "off":
-		f := devportalclient.NewFactory(logger)
-		c, err := f.CreateBitriseConnection(cfg.BuildURL, cfg.BuildAPIToken)
+		logger.Warnf("Offline mode: the Developer Portal is not accessed, only the uploaded certificates and the local provisioning profiles are used")
+	} else {
+		authInputs := appleauth.Inputs{
+			APIIssuer:  cfg.APIIssuer,
+			APIKeyPath: string(cfg.APIKeyPath),
+		}
+		if err := authInputs.Validate(); err != nil {
+			failf("Issue with authentication related inputs: %v", err)
+		}
+
+		authSources, err := parseAuthSources(cfg.BitriseConnection)
+		if err != nil {
+			failf("Invalid input: unexpected value for Bitrise Apple Developer Connection (%s)", cfg.BitriseConnection)
+		}
+
+		isRunningOnBitrise := cfg.BuildURL != "" && cfg.BuildAPIToken != ""
+
+		switch {
+		case cfg.BitriseConnection != "off" && !isRunningOnBitrise:
+			fmt.Println()
+			logger.Warnf("Connected Apple Developer Portal Account not found. Step is not running on bitrise.io: BITRISE_BUILD_URL and BITRISE_BUILD_API_TOKEN envs are not set")
+		case cfg.BitriseConnection != "off":
+			f := devportalclient.NewFactory(logger)
+			c, err := f.CreateBitriseConnection(cfg.BuildURL, cfg.BuildAPIToken)
+			if err != nil {
+				failf(err.Error())
+			}
+
+			connection = c
+		}
+
+		var cassettePth string
+		if cfg.RecordAPICassette {
+			cassettePth = filepath.Join(cfg.OutputDir, "appstoreconnect_cassette.json")
+		}
+
+		apiRateLimiter = newRateLimiter()
+		devPortalClient, apiClient, err = createClient(ctx, authSources, authInputs, cfg.TeamID, connection, apiClientOptions{
+			BaseURL:     cfg.APIBaseURL,
+			CassettePth: cassettePth,
+			RateLimiter: apiRateLimiter,
+		})
 		if err != nil {
 			failf(err.Error())
 		}
+		if apiClient != nil {
+			devPortalClient = newAppIDPlatformDevPortalClient(devPortalClient, apiClient, appIDPlatforms(signedAppLayouts.All()...))
+		}
+		devPortalClient = newContextDevPortalClient(ctx, devPortalClient)
 
-		connection = c
+		// Exported right away, as the cassette is the most useful when the Step fails
+		if apiClient != nil && cassettePth != "" {
+			exportOutputs(logger, map[string]string{"BITRISE_API_CASSETTE_PATH": cassettePth})
+		}
+	}
+	if apiClient != nil {
+		devPortalClient = newDeviceRegisteringDevPortalClient(devPortalClient, apiClient)
 	}
 
-	devPortalClient, err := createClient(authSources, authInputs, cfg.TeamID, connection)
-	if err != nil {
-		failf(err.Error())
+	var profileNameTemplate *ProfileNameTemplate
+	if cfg.ProfileNameTemplate != "" {
+		template, err := NewProfileNameTemplate(cfg.ProfileNameTemplate)
+		if err != nil {
+			failf("Invalid input: %s", err)
+		}
+		profileNameTemplate = &template
+		devPortalClient = newProfileNamingDevPortalClient(devPortalClient, template, cfg.TeamID, cfg.ProfileNameSuffix)
+	}
+	bitriseProfiles := NewBitriseProfileMatcher(apiClient, profileNameTemplate, cfg.TeamID, cfg.ProfileNameSuffix)
+
+	var portalPlan *PortalPlan
+	if cfg.DryRun {
+		fmt.Println()
+		logger.Warnf("Dry-run: Developer Portal changes are recorded, but not executed")
+		portalPlan = &PortalPlan{}
+		devPortalClient = newDryRunDevPortalClient(devPortalClient, portalPlan, appIDPlatforms(signedAppLayouts.All()...))
+	}
+
+	// Wraps the dry-run client, so that the adopted profiles are not recorded as planned creations
+	if cfg.AdoptProfiles {
+		if apiClient == nil {
+			failf("Adopting existing profiles requires App Store Connect API key authentication")
+		}
+		devPortalClient = newProfileAdoptingDevPortalClient(devPortalClient, apiClient, cfg.MinProfileDaysValid)
+	}
+
+	deviceBudget := cfg.DeviceBudget
+	if deviceBudget == 0 {
+		deviceBudget = appleDeviceLimit
+	}
+	if deviceBudget < 0 || deviceBudget > appleDeviceLimit {
+		failf("Invalid input: device_budget should be between 1 and %d", appleDeviceLimit)
+	}
+	if apiClient != nil {
+		devPortalClient = newDeviceBudgetDevPortalClient(devPortalClient, apiClient, deviceBudget)
+	}
+
+	if cfg.Mode != EnsureMode && cfg.Mode != OfflineMode {
+		if apiClient == nil {
+			failf("The %s mode requires App Store Connect API key authentication", cfg.Mode)
+		}
+
+		certificateManager := NewCertificateManager(apiClient, bitriseProfiles, portalPlan)
+		outputs := map[string]string{}
+		switch cfg.Mode {
+		case ListCertificatesMode:
+			fmt.Println()
+			logger.Infof("Listing certificates")
+			certificates, err := certificateManager.ListCertificates("")
+			if err != nil {
+				failf(err.Error())
+			}
+			printCertificates(certificates)
+		case RevokeCertificatesMode:
+			serials := splitAndClean(cfg.RevokeCertificateSerials, "|", true)
+			if len(serials) == 0 {
+				failf("No certificate serial provided (revoke_certificate_serials)")
+			}
+
+			fmt.Println()
+			logger.Infof("Revoking certificates")
+			if err := certificateManager.Revoke(serials); err != nil {
+				failf(err.Error())
+			}
+		case RotateCertificatesMode:
+			if !cfg.DryRun && cfg.GeneratedCertificatePassphrase == "" {
+				failf("Rotating certificates requires a passphrase (generated_certificate_passphrase) to protect the exported certificate")
+			}
+
+			fmt.Println()
+			logger.Infof("Rotating certificates")
+			p12Pth := filepath.Join(cfg.OutputDir, "generated_certificate.p12")
+			certificate, err := certificateManager.Rotate(distribution, cfg.CertificateRotationDays, cfg.GeneratedCertificatePassphrase, p12Pth)
+			if certificate != nil {
+				outputs["BITRISE_GENERATED_CERTIFICATE_PATH"] = p12Pth
+			}
+			if err != nil {
+				// The new certificate is already on the Developer Portal, its private key is only available in the p12 file
+				if certificate != nil {
+					exportOutputs(logger, map[string]string{"BITRISE_GENERATED_CERTIFICATE_PATH": p12Pth})
+				}
+				failf(err.Error())
+			}
+		case PruneMode:
+			liveBundleIDs := splitAndClean(cfg.LiveBundleIDs, "|", true)
+			if len(liveBundleIDs) == 0 {
+				_, appLayout := openProject(cfg, logger)
+				signedAppLayouts, err := NewSignedAppLayouts(appLayout, cfg.MacCatalyst, cfg.MacCatalystDerivedBundleIDs)
+				if err != nil {
+					failf(err.Error())
+				}
+				liveBundleIDs = signedAppLayouts.BundleIDs()
+			}
+
+			fmt.Println()
+			logger.Infof("Searching for stale Bitrise managed profiles and app IDs")
+			logger.Printf("Live bundle IDs: %s", liveBundleIDs)
+			pruner := NewPruner(apiClient, bitriseProfiles, portalPlan)
+			orphans, err := pruner.FindOrphans(liveBundleIDs)
+			if err != nil {
+				failf(err.Error())
+			}
+			printOrphans(orphans)
+
+			if !cfg.DryRun && !cfg.ConfirmPrune {
+				failf("Deleting the listed profiles and app IDs requires confirmation (confirm_prune)")
+			}
+			if err := pruner.Prune(orphans); err != nil {
+				failf(err.Error())
+			}
+		case ManageDevicesMode:
+			deviceManager := NewDeviceManager(apiClient, portalPlan)
+
+			fmt.Println()
+			logger.Infof("Listing registered devices")
+			devices, err := deviceManager.ListDevices()
+			if err != nil {
+				failf(err.Error())
+			}
+			printDeviceSlots(NewDeviceSlots(devices, deviceBudget))
+
+			keepUDIDs := splitAndClean(cfg.KeepDeviceUDIDs, "|", true)
+			if len(keepUDIDs) > 0 {
+				fmt.Println()
+				logger.Infof("Disabling the devices not in the keep-list")
+				if err := deviceManager.DisableDevices(devices, keepUDIDs); err != nil {
+					failf(err.Error())
+				}
+			}
+		}
+
+		if cfg.DryRun {
+			outputs["BITRISE_DEVELOPER_PORTAL_PLAN_PATH"] = writePortalPlan(portalPlan, cfg.OutputDir)
+		}
+
+		logAPIQuota(logger, apiRateLimiter)
+
+		fmt.Println()
+		logger.Infof("Exporting outputs")
+		exportOutputs(logger, outputs)
+		return
 	}
 
 	// Create codesign manager
-	keychain, err := keychain.New(cfg.KeychainPath, cfg.KeychainPassword, command.NewFactory(env.NewRepository()))
-	if err != nil {
-		failf(fmt.Sprintf("failed to initialize keychain: %s", err))
+	var assetWriter autocodesign.AssetWriter
+	if cfg.DryRun {
+		assetWriter = dryRunAssetWriter{}
+	} else {
+		var certificateKeychain Keychain
+		if cfg.KeychainBackend == P12DirBackend {
+			if cfg.KeystoreDir == "" {
+				failf("Keystore directory (keystore_dir) is required if the keychain backend is %s", P12DirBackend)
+			}
+			logger.Printf("Writing the certificates into %s", cfg.KeystoreDir)
+			certificateKeychain = newP12Keystore(cfg.KeystoreDir, cfg.KeychainPassword)
+		} else if cfg.KeychainBackend == TemporaryKeychainBackend {
+			pth, keychain, err := newTemporaryKeychain(command.NewFactory(env.NewRepository()))
+			if err != nil {
+				failf("Failed to create temporary keychain: %s", err)
+			}
+			// Exported right away, so that the cleanup removes the keychain even if the Step fails later
+			exportOutputs(logger, map[string]string{"BITRISE_TEMPORARY_KEYCHAIN_PATH": pth})
+			certificateKeychain = *keychain
+		} else {
+			keychain, err := keychain.New(cfg.KeychainPath, cfg.KeychainPassword, command.NewFactory(env.NewRepository()))
+			if err != nil {
+				failf(fmt.Sprintf("failed to initialize keychain: %s", err))
+			}
+			certificateKeychain = *keychain
+		}
+		assetWriter = newKeychainAssetWriter(certificateKeychain, installedProfilesDirs(), cfg.ProfilesOutputDir)
 	}
 
-	certDownloader := certdownloader.NewDownloader(certsWithPrivateKey, retry.NewHTTPClient().StandardClient())
-	localCodeSignAssetManager := localcodesignasset.NewManager(localcodesignasset.NewProvisioningProfileProvider(), localcodesignasset.NewProvisioningProfileConverter())
-	manager := autocodesign.NewCodesignAssetManager(devPortalClient, certDownloader, codesignasset.NewWriter(*keychain), localCodeSignAssetManager)
+	macCertificateProvider := newMacCertificateProvider(certdownloader.NewDownloader(certsWithPrivateKey, retry.NewHTTPClient().StandardClient()), distribution)
+	var certificateProvider autocodesign.CertificateProvider = macCertificateProvider
+	var certificateGenerator *generatingCertificateProvider
+	switch {
+	case cfg.GenerateCertificate && cfg.DryRun:
+		logger.Warnf("Dry-run: certificates are not created on the Developer Portal")
+	case cfg.GenerateCertificate:
+		if apiClient == nil {
+			failf("Creating certificates requires App Store Connect API key authentication")
+		}
+		if cfg.GeneratedCertificatePassphrase == "" {
+			failf("Creating certificates requires a passphrase (generated_certificate_passphrase) to protect the exported certificate")
+		}
+
+		generator := NewCertificateGenerator(NewProvisioningService(apiClient))
+		p12Pth := filepath.Join(cfg.OutputDir, "generated_certificate.p12")
+		certificateGenerator = newGeneratingCertificateProvider(certificateProvider, generator, certificateTypes[distribution], cfg.GeneratedCertificatePassphrase, p12Pth)
+		certificateProvider = certificateGenerator
+	}
 
 	// Auto codesign
-	distribution := cfg.DistributionType()
+	var connectionTestDevices, fileTestDevices []devportalservice.TestDevice
+	if connection != nil {
+		connectionTestDevices = connection.TestDevices
+	}
+	if cfg.TestDevicesFile != "" {
+		fileTestDevices, err = readTestDevicesFile(ctx, cfg.TestDevicesFile, retry.NewHTTPClient().StandardClient())
+		if err != nil {
+			failf(err.Error())
+		}
+		logger.Printf("%d test device(s) read from %s", len(fileTestDevices), cfg.TestDevicesFile)
+	}
+
 	var testDevices []devportalservice.TestDevice
-	if cfg.RegisterTestDevices && connection != nil {
-		testDevices = connection.TestDevices
+	if cfg.RegisterTestDevices {
+		testDevices = connectionTestDevices
+	}
+	testDevices = mergeTestDevices(testDevices, fileTestDevices)
+	if len(testDevices) > 0 && apiClient != nil {
+		if err := renameGenericDevices(devPortalClient, NewProvisioningService(apiClient), testDevices, portalPlan); err != nil {
+			logger.Warnf("Failed to rename test devices: %s", err)
+		}
+	}
+
+	profileDeviceFilter, err := NewDeviceFilter(
+		splitAndClean(cfg.ProfileDeviceUDIDs, "|", true),
+		cfg.ProfileDeviceNamePattern,
+		splitAndClean(cfg.ProfileDeviceClasses, "|", true),
+		cfg.ProfileTestDevicesOnly,
+		mergeTestDevices(connectionTestDevices, fileTestDevices),
+	)
+	if err != nil {
+		failf("Invalid input: %s", err)
 	}
-	codesignAssetsByDistributionType, err := manager.EnsureCodesignAssets(appLayout, autocodesign.CodesignAssetsOpts{
+	if !profileDeviceFilter.IsEmpty() {
+		devPortalClient = newDeviceFilteringDevPortalClient(devPortalClient, profileDeviceFilter)
+	}
+	// Wraps every other client, so that the dry-run plan records the same app ID names
+	devPortalClient = newAppIDNamingDevPortalClient(devPortalClient)
+
+	profileDirs := installedProfilesDirs()
+	if cfg.ProfilesOutputDir != "" {
+		profileDirs = append(profileDirs, cfg.ProfilesOutputDir)
+	}
+	profileDirProvider := newProfileDirProvider(profileDirs)
+	var profileProvider localcodesignasset.ProvisioningProfileProvider = profileDirProvider
+	var profileConverter localcodesignasset.ProvisioningProfileConverter = profileDirProvider
+	profileURLs := splitAndClean(string(cfg.ProvisioningProfileURLs), "|", true)
+	if len(profileURLs) > 0 {
+		downloader := newProfileDownloader(ctx, profileURLs, retry.NewHTTPClient().StandardClient(), profileProvider, profileConverter)
+		profileProvider, profileConverter = downloader, downloader
+	}
+	localCodeSignAssetManager := newLocalAssetRecorder(localcodesignasset.NewManager(profileProvider, profileConverter))
+	newCodesignAssetManager := func(platform autocodesign.Platform, devPortalClient autocodesign.DevPortalClient, deviceClasses []appstoreconnect.DeviceClass) autocodesign.CodesignAssetManager {
+		if cfg.Mode == OfflineMode {
+			return NewOfflineCodesignAssetManager(certificateProvider, profileProvider, profileConverter, assetWriter, deviceClasses)
+		}
+		if isMacPlatform(platform) {
+			return newMacCodesignAssetManager(platform, distribution, devPortalClient, certificateProvider, assetWriter, localCodeSignAssetManager)
+		}
+		return autocodesign.NewCodesignAssetManager(devPortalClient, certificateProvider, assetWriter, localCodeSignAssetManager)
+	}
+
+	codesignAssetsOpts := autocodesign.CodesignAssetsOpts{
 		DistributionType:       distribution,
 		BitriseTestDevices:     testDevices,
 		MinProfileValidityDays: cfg.MinProfileDaysValid,
 		VerboseLog:             cfg.VerboseLog,
-	})
-	if err != nil {
-		failf(fmt.Sprintf("Automatic code signing failed: %s", err))
+	}
+	var codesignAssetsByDistributionType map[autocodesign.DistributionType]autocodesign.AppCodesignAssets
+	if mainAppLayout := signedAppLayouts.Main; mainAppLayout != nil {
+		manager := newCodesignAssetManager(mainAppLayout.Platform, devPortalClient, nil)
+		codesignAssetsByDistributionType, err = manager.EnsureCodesignAssets(*mainAppLayout, codesignAssetsOpts)
+		if err != nil {
+			failf("Automatic code signing failed: %s", wrapContextErr(ctx, err))
+		}
+		// The test devices are registered by the first pass only, the later passes list them as registered devices
+		codesignAssetsOpts.BitriseTestDevices = nil
+	}
+
+	if watchAppLayout := signedAppLayouts.Watch; watchAppLayout != nil {
+		fmt.Println()
+		logger.Infof("Ensuring the watchOS code signing assets")
+		watchDeviceClasses := []appstoreconnect.DeviceClass{appstoreconnect.AppleWatch}
+		watchManager := newCodesignAssetManager(watchAppLayout.Platform, newDeviceFilteringDevPortalClient(devPortalClient, DeviceFilter{Classes: watchDeviceClasses}), watchDeviceClasses)
+		watchCodesignAssetsByDistributionType, err := watchManager.EnsureCodesignAssets(*watchAppLayout, codesignAssetsOpts)
+		if err != nil {
+			failf("Automatic code signing failed for watchOS: %s", wrapContextErr(ctx, err))
+		}
+		codesignAssetsOpts.BitriseTestDevices = nil
+		codesignAssetsByDistributionType = mergeCodesignAssets(codesignAssetsByDistributionType, watchCodesignAssetsByDistributionType)
+	}
+
+	var catalystCodesignAssetsByDistributionType map[autocodesign.DistributionType]autocodesign.AppCodesignAssets
+	if catalystAppLayout := signedAppLayouts.Catalyst; catalystAppLayout != nil {
+		fmt.Println()
+		logger.Infof("Ensuring the Mac Catalyst code signing assets")
+		catalystManager := newCodesignAssetManager(catalystAppLayout.Platform, devPortalClient, nil)
+		catalystCodesignAssetsByDistributionType, err = catalystManager.EnsureCodesignAssets(*catalystAppLayout, codesignAssetsOpts)
+		if err != nil {
+			failf("Automatic code signing failed for Mac Catalyst: %s", wrapContextErr(ctx, err))
+		}
+	}
+
+	if len(profileURLs) > 0 || cfg.ProfilesOutputDir != "" {
+		// autocodesign only installs the generated profiles, the used downloaded and installed ones are written here
+		fmt.Println()
+		logger.Infof("Installing provisioning profiles")
+		for _, assets := range []map[autocodesign.DistributionType]autocodesign.AppCodesignAssets{codesignAssetsByDistributionType, catalystCodesignAssetsByDistributionType} {
+			if err := assetWriter.Write(assets); err != nil {
+				failf("Failed to install provisioning profiles: %s", err)
+			}
+		}
+	}
+
+	installerCertificate := ""
+	if _, usesInstaller := installerCertificateTypes[distribution]; signsForMac && usesInstaller {
+		if cert := macCertificateProvider.InstallerCertificate(); cert != nil {
+			fmt.Println()
+			logger.Infof("Installing Mac installer certificate")
+			if err := assetWriter.InstallCertificate(*cert); err != nil {
+				failf("Failed to install Mac installer certificate: %s", err)
+			}
+			installerCertificate = cert.CommonName
+		} else {
+			logger.Warnf("No Mac installer certificate provided, Mac App Store packages can not be exported")
+		}
+	}
+
+	if cfg.DryRun {
+		planPth := writePortalPlan(portalPlan, cfg.OutputDir)
+		logAPIQuota(logger, apiRateLimiter)
+
+		fmt.Println()
+		logger.Infof("Exporting outputs")
+		exportOutputs(logger, map[string]string{"BITRISE_DEVELOPER_PORTAL_PLAN_PATH": planPth})
+		return
 	}
 
 	if err := project.ForceCodesignAssets(distribution, codesignAssetsByDistributionType); err != nil {
@@ -149,10 +528,32 @@ https://blog.bitrise.io/post/simplifying-automatic-code-signing-on-bitrise
 	fmt.Println()
 	logger.Infof("Exporting outputs")
 
+	reportPth := filepath.Join(cfg.OutputDir, "codesign_report.json")
+	localProfileUUIDs := localCodeSignAssetManager.profileUUIDs
+	if cfg.Mode == OfflineMode {
+		localProfileUUIDs = profileUUIDsOfCodesignAssets(codesignAssetsByDistributionType, catalystCodesignAssetsByDistributionType)
+	}
+	report := NewRunReport(distribution, codesignAssetsByDistributionType, localProfileUUIDs)
+	report.AddCodesignAssets(catalystCodesignAssetsByDistributionType, localProfileUUIDs)
+	if err := report.WriteToFile(reportPth); err != nil {
+		failf("Failed to write code signing report: %s", err)
+	}
+
+	exportOptions, err := newExportOptions(distribution, codesignAssetsByDistributionType[distribution], usesICloud, installerCertificate)
+	if err != nil {
+		failf("Failed to create export options: %s", err)
+	}
+	exportOptionsPth := filepath.Join(cfg.OutputDir, "exportOptions.plist")
+	if err := exportOptions.WriteToFile(exportOptionsPth); err != nil {
+		failf("Failed to write export options: %s", err)
+	}
+
 	teamID := codesignAssetsByDistributionType[distribution].Certificate.TeamID
 	outputs := map[string]string{
-		"BITRISE_EXPORT_METHOD":  cfg.Distribution,
-		"BITRISE_DEVELOPER_TEAM": teamID,
+		"BITRISE_EXPORT_METHOD":        cfg.Distribution,
+		"BITRISE_DEVELOPER_TEAM":       teamID,
+		"BITRISE_CODESIGN_REPORT_PATH": reportPth,
+		"BITRISE_EXPORT_OPTIONS_PLIST": exportOptionsPth,
 	}
 
 	settings, ok := codesignAssetsByDistributionType[autocodesign.Development]
@@ -169,6 +570,12 @@ https://blog.bitrise.io/post/simplifying-automatic-code-signing-on-bitrise
 		}
 
 		outputs["BITRISE_DEVELOPMENT_PROFILE"] = profile.Attributes().UUID
+
+		profiles, err := profileUUIDsByBundleID(settings)
+		if err != nil {
+			failf("Failed to list development provisioning profiles: %s", err)
+		}
+		outputs["BITRISE_DEVELOPMENT_PROFILES"] = profiles
 	}
 
 	if distribution != autocodesign.Development {
@@ -189,8 +596,87 @@ https://blog.bitrise.io/post/simplifying-automatic-code-signing-on-bitrise
 		}
 
 		outputs["BITRISE_PRODUCTION_PROFILE"] = profile.Attributes().UUID
+
+		profiles, err := profileUUIDsByBundleID(settings)
+		if err != nil {
+			failf("Failed to list production provisioning profiles: %s", err)
+		}
+		outputs["BITRISE_PRODUCTION_PROFILES"] = profiles
+	}
+
+	if catalystCodesignAssetsByDistributionType != nil {
+		catalystExportOptions, err := newExportOptions(distribution, catalystCodesignAssetsByDistributionType[distribution], usesICloud, installerCertificate)
+		if err != nil {
+			failf("Failed to create Mac Catalyst export options: %s", err)
+		}
+		catalystExportOptionsPth := filepath.Join(cfg.OutputDir, "exportOptions_maccatalyst.plist")
+		if err := catalystExportOptions.WriteToFile(catalystExportOptionsPth); err != nil {
+			failf("Failed to write Mac Catalyst export options: %s", err)
+		}
+		outputs["BITRISE_MAC_CATALYST_EXPORT_OPTIONS_PLIST"] = catalystExportOptionsPth
+
+		// Listed in separate outputs, as the Mac Catalyst bundle IDs may be the same as the iOS ones
+		if settings, ok := catalystCodesignAssetsByDistributionType[autocodesign.Development]; ok {
+			profiles, err := profileUUIDsByBundleID(settings)
+			if err != nil {
+				failf("Failed to list Mac Catalyst development provisioning profiles: %s", err)
+			}
+			outputs["BITRISE_MAC_CATALYST_DEVELOPMENT_PROFILES"] = profiles
+		}
+		if settings, ok := catalystCodesignAssetsByDistributionType[distribution]; ok && distribution != autocodesign.Development {
+			profiles, err := profileUUIDsByBundleID(settings)
+			if err != nil {
+				failf("Failed to list Mac Catalyst production provisioning profiles: %s", err)
+			}
+			outputs["BITRISE_MAC_CATALYST_PRODUCTION_PROFILES"] = profiles
+		}
+	}
+
+	if certificateGenerator != nil && certificateGenerator.generated != nil {
+		outputs["BITRISE_GENERATED_CERTIFICATE_PATH"] = certificateGenerator.p12Pth
+	}
+
+	logAPIQuota(logger, apiRateLimiter)
+	exportOutputs(logger, outputs)
+}
+
+func openProject(cfg Config, logger log.Logger) (Project, TargetAppLayout) {
+	fmt.Println()
+	logger.Infof("Analyzing project")
+	project, err := NewProject(cfg.ProjectPath, cfg.Scheme, cfg.Configuration)
+	if err != nil {
+		failf(err.Error())
 	}
 
+	appLayout, err := project.AppLayout(cfg.SignUITestTargets)
+	if err != nil {
+		failf(err.Error())
+	}
+
+	return project, appLayout
+}
+
+func writePortalPlan(plan *PortalPlan, outputDir string) string {
+	plan.Print()
+
+	pth := filepath.Join(outputDir, "developer_portal_plan.json")
+	if err := plan.WriteToFile(pth); err != nil {
+		failf("Failed to write Developer Portal plan: %s", err)
+	}
+	return pth
+}
+
+// logAPIQuota logs the App Store Connect API requests sent by the run and the remaining hourly quota.
+func logAPIQuota(logger log.Logger, limiter *rateLimiter) {
+	if limiter == nil || limiter.Requests() == 0 {
+		return
+	}
+
+	fmt.Println()
+	logger.Printf("%s", limiter.Summary())
+}
+
+func exportOutputs(logger log.Logger, outputs map[string]string) {
 	for k, v := range outputs {
 		logger.Donef("%s=%s", k, v)
 		if err := tools.ExportEnvironmentWithEnvman(k, v); err != nil {
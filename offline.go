@@ -0,0 +1,388 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-xcode/certificateutil"
+	"github.com/bitrise-io/go-xcode/devportalservice"
+	"github.com/bitrise-io/go-xcode/profileutil"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/devportalclient/appstoreconnect"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/localcodesignasset"
+)
+
+// MissingProfile tells why none of the local provisioning profiles can sign a target.
+type MissingProfile struct {
+	BundleID     string
+	Distribution autocodesign.DistributionType
+	Reasons      []string
+}
+
+// MissingCodesignAssetsError lists the targets which can not be signed with the local code signing assets.
+type MissingCodesignAssetsError struct {
+	MissingCertificates []autocodesign.DistributionType
+	MissingProfiles     []MissingProfile
+}
+
+// Error ...
+func (e MissingCodesignAssetsError) Error() string {
+	lines := []string{"local code signing assets are missing:"}
+	for _, distribution := range e.MissingCertificates {
+		lines = append(lines, fmt.Sprintf("- no valid %s certificate uploaded", certificateTypes[distribution]))
+	}
+	for _, missing := range e.MissingProfiles {
+		lines = append(lines, fmt.Sprintf("- %s (%s):", missing.BundleID, missing.Distribution))
+		for _, reason := range missing.Reasons {
+			lines = append(lines, "  - "+reason)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// offlineCodesignAssetManager ensures the code signing assets without accessing the Developer Portal:
+// the uploaded certificates are matched to the local provisioning profiles by the profiles' developer certificates.
+type offlineCodesignAssetManager struct {
+	certificateProvider autocodesign.CertificateProvider
+	profileProvider     localcodesignasset.ProvisioningProfileProvider
+	profileConverter    localcodesignasset.ProvisioningProfileConverter
+	assetWriter         autocodesign.AssetWriter
+	deviceClasses       []appstoreconnect.DeviceClass
+}
+
+// NewOfflineCodesignAssetManager returns an autocodesign.CodesignAssetManager, which only uses the local code signing assets.
+// The profiles have to include the test devices of the given device classes, or of the app layout's platform if not set.
+func NewOfflineCodesignAssetManager(certificateProvider autocodesign.CertificateProvider, profileProvider localcodesignasset.ProvisioningProfileProvider, profileConverter localcodesignasset.ProvisioningProfileConverter, assetWriter autocodesign.AssetWriter, deviceClasses []appstoreconnect.DeviceClass) autocodesign.CodesignAssetManager {
+	return offlineCodesignAssetManager{
+		certificateProvider: certificateProvider,
+		profileProvider:     profileProvider,
+		profileConverter:    profileConverter,
+		assetWriter:         assetWriter,
+		deviceClasses:       deviceClasses,
+	}
+}
+
+// EnsureCodesignAssets ...
+func (m offlineCodesignAssetManager) EnsureCodesignAssets(appLayout autocodesign.AppLayout, opts autocodesign.CodesignAssetsOpts) (map[autocodesign.DistributionType]autocodesign.AppCodesignAssets, error) {
+	fmt.Println()
+	log.Infof("Downloading certificates")
+
+	certs, err := m.certificateProvider.GetCertificates()
+	if err != nil {
+		return nil, fmt.Errorf("failed to download certificates: %w", err)
+	}
+	certsByType, err := localCertificatesByType(certs)
+	if err != nil {
+		return nil, err
+	}
+
+	profiles, err := m.profileProvider.ListProvisioningProfiles()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list local provisioning profiles: %s", err)
+	}
+	log.Printf("%d local provisioning profiles found", len(profiles))
+
+	deviceClasses := m.deviceClasses
+	if len(deviceClasses) == 0 {
+		deviceClasses = platformDeviceClasses(appLayout.Platform)
+	}
+	var deviceUDIDs []string
+	for _, testDevice := range opts.BitriseTestDevices {
+		if hasAnyDeviceClass(testDevice, deviceClasses) {
+			deviceUDIDs = append(deviceUDIDs, testDevice.DeviceID)
+		}
+	}
+
+	// The development assets are optional for the other distribution types, unless UITest targets are signed
+	uiTestTargets := len(appLayout.UITestTargetBundleIDs) > 0
+	distrTypes := []autocodesign.DistributionType{opts.DistributionType}
+	if opts.DistributionType != autocodesign.Development && (uiTestTargets || len(certsByType[appstoreconnect.IOSDevelopment]) > 0) {
+		distrTypes = append(distrTypes, autocodesign.Development)
+	}
+
+	codesignAssetsByDistributionType := map[autocodesign.DistributionType]autocodesign.AppCodesignAssets{}
+	var missing MissingCodesignAssetsError
+	for _, distrType := range distrTypes {
+		fmt.Println()
+		log.Infof("Matching local %s provisioning profiles", distrType)
+
+		distrCerts := certsByType[certificateTypes[distrType]]
+		if len(distrCerts) == 0 {
+			missing.MissingCertificates = append(missing.MissingCertificates, distrType)
+			continue
+		}
+
+		var profileDeviceUDIDs []string
+		if autocodesign.DistributionTypeRequiresDeviceList([]autocodesign.DistributionType{distrType}) {
+			profileDeviceUDIDs = deviceUDIDs
+		}
+
+		assets, missingProfiles, err := m.matchProfiles(appLayout, distrType, distrCerts, profiles, profileDeviceUDIDs, opts.MinProfileValidityDays)
+		if err != nil {
+			return nil, err
+		}
+		if len(missingProfiles) > 0 && distrType != opts.DistributionType && !uiTestTargets {
+			log.Warnf("Skipping the optional %s code signing assets: no matching local provisioning profile for %d target(s)", distrType, len(missingProfiles))
+			continue
+		}
+		if len(missingProfiles) > 0 {
+			missing.MissingProfiles = append(missing.MissingProfiles, missingProfiles...)
+			continue
+		}
+
+		log.Printf("certificate: %s", assets.Certificate.CommonName)
+		for bundleID, profile := range assets.ArchivableTargetProfilesByBundleID {
+			log.Printf("- %s: %s", bundleID, profile.Attributes().Name)
+		}
+		for bundleID, profile := range assets.UITestTargetProfilesByBundleID {
+			log.Printf("- %s: %s", bundleID, profile.Attributes().Name)
+		}
+		codesignAssetsByDistributionType[distrType] = *assets
+	}
+
+	if len(missing.MissingCertificates) > 0 || len(missing.MissingProfiles) > 0 {
+		return nil, missing
+	}
+
+	fmt.Println()
+	log.Infof("Installing certificates and profiles")
+	if err := m.assetWriter.Write(codesignAssetsByDistributionType); err != nil {
+		return nil, fmt.Errorf("failed to install codesigning files: %w", err)
+	}
+
+	return codesignAssetsByDistributionType, nil
+}
+
+// matchProfiles selects the certificate, which the local profiles of the most targets include,
+// and returns the profiles, or why no profile can be used for a target.
+func (m offlineCodesignAssetManager) matchProfiles(appLayout autocodesign.AppLayout, distrType autocodesign.DistributionType, certs []certificateutil.CertificateInfoModel, profiles []profileutil.ProvisioningProfileInfoModel, deviceUDIDs []string, minProfileDaysValid int) (*autocodesign.AppCodesignAssets, []MissingProfile, error) {
+	var bestProfiles, bestUITestProfiles map[string]profileutil.ProvisioningProfileInfoModel
+	var bestMissing []MissingProfile
+	var bestCert certificateutil.CertificateInfoModel
+	for i, cert := range certs {
+		matcher := localProfileMatcher{
+			platform:            appLayout.Platform,
+			distribution:        distrType,
+			certificate:         cert,
+			deviceUDIDs:         deviceUDIDs,
+			minProfileDaysValid: minProfileDaysValid,
+		}
+
+		profilesByBundleID := map[string]profileutil.ProvisioningProfileInfoModel{}
+		var missing []MissingProfile
+		for bundleID, entitlements := range appLayout.EntitlementsByArchivableTargetBundleID {
+			profile, reasons := matcher.find(profiles, bundleID, entitlements)
+			if profile == nil {
+				missing = append(missing, MissingProfile{BundleID: bundleID, Distribution: distrType, Reasons: reasons})
+				continue
+			}
+			profilesByBundleID[bundleID] = *profile
+		}
+
+		uiTestProfilesByBundleID := map[string]profileutil.ProvisioningProfileInfoModel{}
+		if distrType == autocodesign.Development {
+			for _, bundleID := range appLayout.UITestTargetBundleIDs {
+				wildcardBundleID, err := autocodesign.CreateWildcardBundleID(bundleID)
+				if err != nil {
+					return nil, nil, fmt.Errorf("could not create wildcard bundle id: %s", err)
+				}
+
+				// Capabilities are not supported for UITest targets.
+				profile, reasons := matcher.find(profiles, wildcardBundleID, nil)
+				if profile == nil {
+					missing = append(missing, MissingProfile{BundleID: bundleID, Distribution: distrType, Reasons: reasons})
+					continue
+				}
+				uiTestProfilesByBundleID[bundleID] = *profile
+			}
+		}
+
+		if i == 0 || len(missing) < len(bestMissing) {
+			bestProfiles, bestUITestProfiles, bestMissing, bestCert = profilesByBundleID, uiTestProfilesByBundleID, missing, cert
+		}
+		if len(missing) == 0 {
+			break
+		}
+	}
+
+	if len(bestMissing) > 0 {
+		sort.Slice(bestMissing, func(i, j int) bool { return bestMissing[i].BundleID < bestMissing[j].BundleID })
+		return nil, bestMissing, nil
+	}
+
+	assets := autocodesign.AppCodesignAssets{
+		ArchivableTargetProfilesByBundleID: map[string]autocodesign.Profile{},
+		Certificate:                        bestCert,
+	}
+	for bundleID, info := range bestProfiles {
+		profile, err := m.profileConverter.ProfileInfoToProfile(info)
+		if err != nil {
+			return nil, nil, err
+		}
+		assets.ArchivableTargetProfilesByBundleID[bundleID] = profile
+	}
+	if len(bestUITestProfiles) > 0 {
+		assets.UITestTargetProfilesByBundleID = map[string]autocodesign.Profile{}
+		for bundleID, info := range bestUITestProfiles {
+			profile, err := m.profileConverter.ProfileInfoToProfile(info)
+			if err != nil {
+				return nil, nil, err
+			}
+			assets.UITestTargetProfilesByBundleID[bundleID] = profile
+		}
+	}
+
+	return &assets, nil, nil
+}
+
+// profileUUIDsOfCodesignAssets returns the UUIDs of every profile of the code signing assets.
+func profileUUIDsOfCodesignAssets(codesignAssets ...map[autocodesign.DistributionType]autocodesign.AppCodesignAssets) map[string]bool {
+	uuids := map[string]bool{}
+	for _, codesignAssetsByDistributionType := range codesignAssets {
+		for _, assets := range codesignAssetsByDistributionType {
+			for _, profiles := range []map[string]autocodesign.Profile{assets.ArchivableTargetProfilesByBundleID, assets.UITestTargetProfilesByBundleID} {
+				for _, profile := range profiles {
+					uuids[profile.Attributes().UUID] = true
+				}
+			}
+		}
+	}
+	return uuids
+}
+
+// localProfileMatcher checks the local profiles the same way as localcodesignasset.Manager,
+// but tells why a profile does not match.
+type localProfileMatcher struct {
+	platform            autocodesign.Platform
+	distribution        autocodesign.DistributionType
+	certificate         certificateutil.CertificateInfoModel
+	deviceUDIDs         []string
+	minProfileDaysValid int
+}
+
+// find returns the first matching profile of the bundle ID, or the reasons why none of the profiles match.
+func (m localProfileMatcher) find(profiles []profileutil.ProvisioningProfileInfoModel, bundleID string, entitlements autocodesign.Entitlements) (*profileutil.ProvisioningProfileInfoModel, []string) {
+	var reasons []string
+	for _, profile := range profiles {
+		if profile.BundleID != bundleID {
+			continue
+		}
+
+		mismatches := m.mismatches(profile, entitlements)
+		if len(mismatches) == 0 {
+			return &profile, nil
+		}
+		reasons = append(reasons, fmt.Sprintf("%s (%s): %s", profile.Name, profile.UUID, strings.Join(mismatches, ", ")))
+	}
+
+	if len(reasons) == 0 {
+		return nil, []string{fmt.Sprintf("no provisioning profile found for the bundle ID %s", bundleID)}
+	}
+	return nil, reasons
+}
+
+func (m localProfileMatcher) mismatches(profile profileutil.ProvisioningProfileInfoModel, entitlements autocodesign.Entitlements) []string {
+	var mismatches []string
+
+	if profileType := profileTypeOfPlatform(m.platform); profile.Type != profileType {
+		mismatches = append(mismatches, fmt.Sprintf("platform is %s, not %s", profile.Type, profileType))
+	}
+
+	if autocodesign.DistributionType(profile.ExportType) != m.distribution {
+		mismatches = append(mismatches, fmt.Sprintf("distribution type is %s, not %s", profile.ExportType, m.distribution))
+	}
+
+	expiration := time.Now()
+	if m.minProfileDaysValid > 0 {
+		expiration = expiration.AddDate(0, 0, m.minProfileDaysValid)
+	}
+	if !expiration.Before(profile.ExpirationDate) {
+		mismatches = append(mismatches, fmt.Sprintf("expires at %s", profile.ExpirationDate.Format(time.RFC3339)))
+	}
+
+	hasCertificate := false
+	for _, cert := range profile.DeveloperCertificates {
+		if cert.Serial == m.certificate.Serial {
+			hasCertificate = true
+			break
+		}
+	}
+	if !hasCertificate {
+		mismatches = append(mismatches, fmt.Sprintf("does not include the certificate %s (serial: %s)", m.certificate.CommonName, m.certificate.Serial))
+	}
+
+	if missingEntitlements := missingProfileEntitlements(profile, entitlements); len(missingEntitlements) > 0 {
+		mismatches = append(mismatches, fmt.Sprintf("missing entitlements: %s", strings.Join(missingEntitlements, ", ")))
+	}
+
+	if !profile.ProvisionsAllDevices {
+		var missingDevices []string
+		for _, udid := range m.deviceUDIDs {
+			if !containsUDID(profile.ProvisionedDevices, udid) {
+				missingDevices = append(missingDevices, udid)
+			}
+		}
+		if len(missingDevices) > 0 {
+			mismatches = append(mismatches, fmt.Sprintf("missing devices: %s", strings.Join(missingDevices, ", ")))
+		}
+	}
+
+	// Bitrise managed code signing enforces manual code signing on the project
+	if profile.IsXcodeManaged() {
+		mismatches = append(mismatches, "Xcode managed")
+	}
+
+	return mismatches
+}
+
+func missingProfileEntitlements(profile profileutil.ProvisioningProfileInfoModel, entitlements autocodesign.Entitlements) []string {
+	profileEntitlements := autocodesign.Entitlements(profile.Entitlements)
+
+	var missing []string
+	for key, value := range entitlements {
+		if key == autocodesign.ICloudIdentifiersEntitlementKey {
+			if missingContainers, err := autocodesign.FindMissingContainers(entitlements, profileEntitlements); err != nil || len(missingContainers) > 0 {
+				missing = append(missing, key)
+			}
+		} else if !reflect.DeepEqual(profileEntitlements[key], value) {
+			missing = append(missing, key)
+		}
+	}
+	return missing
+}
+
+func profileTypeOfPlatform(platform autocodesign.Platform) profileutil.ProfileType {
+	switch platform {
+	case autocodesign.TVOS:
+		return profileutil.ProfileTypeTvOs
+	case autocodesign.MacOS, MacCatalyst:
+		return profileutil.ProfileTypeMacOs
+	default:
+		return profileutil.ProfileTypeIos
+	}
+}
+
+// platformDeviceClasses returns the device classes autocodesign adds to the profiles of the platform.
+func platformDeviceClasses(platform autocodesign.Platform) []appstoreconnect.DeviceClass {
+	switch platform {
+	case autocodesign.TVOS:
+		return []appstoreconnect.DeviceClass{appstoreconnect.AppleTV}
+	case autocodesign.MacOS, MacCatalyst:
+		return []appstoreconnect.DeviceClass{appstoreconnect.Mac}
+	default:
+		return []appstoreconnect.DeviceClass{appstoreconnect.Iphone, appstoreconnect.Ipad, appstoreconnect.Ipod, appstoreconnect.AppleWatch}
+	}
+}
+
+func hasAnyDeviceClass(testDevice devportalservice.TestDevice, classes []appstoreconnect.DeviceClass) bool {
+	for _, class := range testDeviceClasses(testDevice) {
+		if containsDeviceClass(classes, class) {
+			return true
+		}
+	}
+	return false
+}
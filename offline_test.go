@@ -0,0 +1,167 @@
+package main
+
+import (
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/go-xcode/certificateutil"
+	"github.com/bitrise-io/go-xcode/devportalservice"
+	"github.com/bitrise-io/go-xcode/exportoptions"
+	"github.com/bitrise-io/go-xcode/profileutil"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/devportalclient/appstoreconnect"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeProfileProvider struct {
+	profiles []profileutil.ProvisioningProfileInfoModel
+}
+
+func (p fakeProfileProvider) ListProvisioningProfiles() ([]profileutil.ProvisioningProfileInfoModel, error) {
+	return p.profiles, nil
+}
+
+type fakeProfileConverter struct{}
+
+func (c fakeProfileConverter) ProfileInfoToProfile(info profileutil.ProvisioningProfileInfoModel) (autocodesign.Profile, error) {
+	return plannedProfile{attributes: appstoreconnect.ProfileAttributes{Name: info.Name, UUID: info.UUID}}, nil
+}
+
+func newTestCertificate(commonName, serial string) certificateutil.CertificateInfoModel {
+	return certificateutil.CertificateInfoModel{
+		CommonName: commonName,
+		Serial:     serial,
+		Certificate: x509.Certificate{
+			NotBefore: time.Now().AddDate(0, 0, -1),
+			NotAfter:  time.Now().AddDate(1, 0, 0),
+		},
+	}
+}
+
+func newTestProfile(uuid, bundleID string, exportType exportoptions.Method, certs ...certificateutil.CertificateInfoModel) profileutil.ProvisioningProfileInfoModel {
+	return profileutil.ProvisioningProfileInfoModel{
+		UUID:                  uuid,
+		Name:                  "Profile " + uuid,
+		BundleID:              bundleID,
+		ExportType:            exportType,
+		Type:                  profileutil.ProfileTypeIos,
+		ExpirationDate:        time.Now().AddDate(0, 6, 0),
+		DeveloperCertificates: certs,
+		ProvisionedDevices:    []string{"device-1"},
+	}
+}
+
+func Test_localProfileMatcher_find(t *testing.T) {
+	cert := newTestCertificate("Apple Development: Bitrise Bot", "1")
+	otherCert := newTestCertificate("Apple Development: Other", "2")
+	matcher := localProfileMatcher{
+		platform:     autocodesign.IOS,
+		distribution: autocodesign.Development,
+		certificate:  cert,
+		deviceUDIDs:  []string{"device-1", "device-2"},
+	}
+
+	matching := newTestProfile("uuid-1", "io.bitrise.app", exportoptions.MethodDevelopment, cert)
+	matching.ProvisionsAllDevices = true
+	expired := newTestProfile("uuid-2", "io.bitrise.app", exportoptions.MethodDevelopment, cert)
+	expired.ExpirationDate = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	wrong := newTestProfile("uuid-3", "io.bitrise.app", exportoptions.MethodAdHoc, otherCert)
+	wrong.Entitlements = map[string]interface{}{"aps-environment": "production"}
+
+	profile, reasons := matcher.find([]profileutil.ProvisioningProfileInfoModel{expired, matching}, "io.bitrise.app", nil)
+	assert.Equal(t, "uuid-1", profile.UUID)
+	assert.Nil(t, reasons)
+
+	profile, reasons = matcher.find([]profileutil.ProvisioningProfileInfoModel{expired, wrong}, "io.bitrise.app", autocodesign.Entitlements{"aps-environment": "development"})
+	assert.Nil(t, profile)
+	assert.Equal(t, []string{
+		"Profile uuid-2 (uuid-2): expires at 2020-01-01T00:00:00Z, missing entitlements: aps-environment, missing devices: device-2",
+		"Profile uuid-3 (uuid-3): distribution type is ad-hoc, not development, does not include the certificate Apple Development: Bitrise Bot (serial: 1), missing entitlements: aps-environment, missing devices: device-2",
+	}, reasons)
+
+	profile, reasons = matcher.find([]profileutil.ProvisioningProfileInfoModel{matching}, "io.bitrise.app.share", nil)
+	assert.Nil(t, profile)
+	assert.Equal(t, []string{"no provisioning profile found for the bundle ID io.bitrise.app.share"}, reasons)
+}
+
+func TestOfflineCodesignAssetManager_EnsureCodesignAssets(t *testing.T) {
+	developmentCert := newTestCertificate("Apple Development: Bitrise Bot", "1")
+	otherDevelopmentCert := newTestCertificate("Apple Development: Other", "2")
+	distributionCert := newTestCertificate("Apple Distribution: Bitrise", "3")
+
+	appLayout := func() autocodesign.AppLayout {
+		return autocodesign.AppLayout{
+			Platform: autocodesign.IOS,
+			EntitlementsByArchivableTargetBundleID: map[string]autocodesign.Entitlements{
+				"io.bitrise.app":       nil,
+				"io.bitrise.app.share": nil,
+			},
+		}
+	}
+	opts := autocodesign.CodesignAssetsOpts{
+		DistributionType: autocodesign.AdHoc,
+		BitriseTestDevices: []devportalservice.TestDevice{
+			{DeviceID: "device-1", DeviceType: "ios"},
+			{DeviceID: "device-2", DeviceType: "tvos"},
+		},
+	}
+
+	t.Run("matches the certificate included in the profiles", func(t *testing.T) {
+		certificateProvider := new(autocodesign.MockCertificateProvider)
+		certificateProvider.On("GetCertificates").Return([]certificateutil.CertificateInfoModel{distributionCert, otherDevelopmentCert, developmentCert}, nil)
+		profileProvider := fakeProfileProvider{profiles: []profileutil.ProvisioningProfileInfoModel{
+			newTestProfile("uuid-1", "io.bitrise.app", exportoptions.MethodAdHoc, distributionCert),
+			newTestProfile("uuid-2", "io.bitrise.app.share", exportoptions.MethodAdHoc, distributionCert),
+			newTestProfile("uuid-3", "io.bitrise.app", exportoptions.MethodDevelopment, developmentCert),
+			newTestProfile("uuid-4", "io.bitrise.app.share", exportoptions.MethodDevelopment, otherDevelopmentCert, developmentCert),
+		}}
+
+		manager := NewOfflineCodesignAssetManager(certificateProvider, profileProvider, fakeProfileConverter{}, dryRunAssetWriter{}, nil)
+		assets, err := manager.EnsureCodesignAssets(appLayout(), opts)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]bool{"uuid-1": true, "uuid-2": true, "uuid-3": true, "uuid-4": true}, profileUUIDsOfCodesignAssets(assets))
+		assert.Equal(t, "1", assets[autocodesign.Development].Certificate.Serial)
+		assert.Equal(t, "3", assets[autocodesign.AdHoc].Certificate.Serial)
+	})
+
+	t.Run("skips the optional development assets", func(t *testing.T) {
+		certificateProvider := new(autocodesign.MockCertificateProvider)
+		certificateProvider.On("GetCertificates").Return([]certificateutil.CertificateInfoModel{distributionCert, developmentCert}, nil)
+		profileProvider := fakeProfileProvider{profiles: []profileutil.ProvisioningProfileInfoModel{
+			newTestProfile("uuid-1", "io.bitrise.app", exportoptions.MethodAdHoc, distributionCert),
+			newTestProfile("uuid-2", "io.bitrise.app.share", exportoptions.MethodAdHoc, distributionCert),
+		}}
+
+		manager := NewOfflineCodesignAssetManager(certificateProvider, profileProvider, fakeProfileConverter{}, dryRunAssetWriter{}, nil)
+		assets, err := manager.EnsureCodesignAssets(appLayout(), opts)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(assets))
+	})
+
+	t.Run("reports the missing assets", func(t *testing.T) {
+		certificateProvider := new(autocodesign.MockCertificateProvider)
+		certificateProvider.On("GetCertificates").Return([]certificateutil.CertificateInfoModel{distributionCert}, nil)
+		profileProvider := fakeProfileProvider{profiles: []profileutil.ProvisioningProfileInfoModel{
+			newTestProfile("uuid-1", "io.bitrise.app", exportoptions.MethodAppStore, distributionCert),
+		}}
+
+		manager := NewOfflineCodesignAssetManager(certificateProvider, profileProvider, fakeProfileConverter{}, dryRunAssetWriter{}, nil)
+		_, err := manager.EnsureCodesignAssets(appLayout(), opts)
+		assert.Equal(t, MissingCodesignAssetsError{
+			MissingProfiles: []MissingProfile{
+				{BundleID: "io.bitrise.app", Distribution: autocodesign.AdHoc, Reasons: []string{"Profile uuid-1 (uuid-1): distribution type is app-store, not ad-hoc"}},
+				{BundleID: "io.bitrise.app.share", Distribution: autocodesign.AdHoc, Reasons: []string{"no provisioning profile found for the bundle ID io.bitrise.app.share"}},
+			},
+		}, err)
+	})
+
+	t.Run("reports the missing certificates", func(t *testing.T) {
+		certificateProvider := new(autocodesign.MockCertificateProvider)
+		certificateProvider.On("GetCertificates").Return([]certificateutil.CertificateInfoModel{developmentCert}, nil)
+
+		manager := NewOfflineCodesignAssetManager(certificateProvider, fakeProfileProvider{}, fakeProfileConverter{}, dryRunAssetWriter{}, nil)
+		_, err := manager.EnsureCodesignAssets(appLayout(), opts)
+		assert.EqualError(t, err, "local code signing assets are missing:\n- no valid IOS_DISTRIBUTION certificate uploaded")
+	})
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/bitrise-io/go-xcode/v2/autocodesign"
+)
+
+// profileUUIDsByBundleID returns the ensured profiles of every archivable and UITest target
+// as a JSON object, mapping bundle IDs to profile UUIDs.
+func profileUUIDsByBundleID(assets autocodesign.AppCodesignAssets) (string, error) {
+	uuids := map[string]string{}
+	for bundleID, profile := range assets.ArchivableTargetProfilesByBundleID {
+		uuids[bundleID] = profile.Attributes().UUID
+	}
+	for bundleID, profile := range assets.UITestTargetProfilesByBundleID {
+		uuids[bundleID] = profile.Attributes().UUID
+	}
+
+	b, err := json.Marshal(uuids)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
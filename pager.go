@@ -0,0 +1,318 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/bitrise-io/go-xcode/certificateutil"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/devportalclient/appstoreconnect"
+)
+
+// appStoreConnectAPIURL prefixes the relationship and paging links of the App Store Connect API responses
+const appStoreConnectAPIURL = "https://api.appstoreconnect.apple.com/v1/"
+
+// maxPageLimit is the largest page size accepted by the App Store Connect API list endpoints
+const maxPageLimit = 200
+
+// PagerOptions ...
+type PagerOptions struct {
+	// Limit is the page size, maxPageLimit if not set.
+	Limit int
+	// Filter is the filter values keyed by the filtered field, for example: `udid`. Empty values are not sent.
+	Filter map[string]string
+	// Include is the related resources included in the responses, for example: `bundleId`.
+	Include []string
+}
+
+// Pager lists an App Store Connect API collection page by page, following the links.next of the responses.
+// Only the cursor of the next page link is used, so the pages are requested from the client's base URL.
+// The caller can stop early, for example once the searched resource is found:
+//
+//	pager := NewPager(client, "devices", PagerOptions{Filter: map[string]string{"udid": udid}})
+//	for pager.HasNext() {
+//		var response appstoreconnect.DevicesResponse
+//		if err := pager.Next(&response); err != nil {
+//			return err
+//		}
+//		...
+//	}
+type Pager struct {
+	client   *appstoreconnect.Client
+	endpoint string
+	query    url.Values
+
+	started bool
+	cursor  string
+}
+
+// NewPager returns a pager of the endpoint, given relative to the API version (for example, `devices`)
+// or as a relationship link (for example, `https://api.appstoreconnect.apple.com/v1/profiles/ID/devices`).
+func NewPager(client *appstoreconnect.Client, endpoint string, opts PagerOptions) *Pager {
+	limit := opts.Limit
+	if limit <= 0 || limit > maxPageLimit {
+		limit = maxPageLimit
+	}
+
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(limit))
+	for field, value := range opts.Filter {
+		if value != "" {
+			query.Set("filter["+field+"]", value)
+		}
+	}
+	if len(opts.Include) > 0 {
+		query.Set("include", strings.Join(opts.Include, ","))
+	}
+
+	return &Pager{
+		client:   client,
+		endpoint: strings.TrimPrefix(endpoint, appStoreConnectAPIURL),
+		query:    query,
+	}
+}
+
+// HasNext reports whether there is a page to request: the first one, or the one linked by the last page.
+func (p *Pager) HasNext() bool {
+	return !p.started || p.cursor != ""
+}
+
+// Next requests the next page and decodes the response into v, for example, an *appstoreconnect.DevicesResponse.
+func (p *Pager) Next(v interface{}) error {
+	if !p.HasNext() {
+		return errors.New("no more pages")
+	}
+
+	query := url.Values{}
+	for key, values := range p.query {
+		query[key] = values
+	}
+	if p.cursor != "" {
+		query.Set("cursor", p.cursor)
+	}
+
+	req, err := p.client.NewRequest(http.MethodGet, p.endpoint+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+
+	var body json.RawMessage
+	if _, err := p.client.Do(req, &body); err != nil {
+		return err
+	}
+
+	var page struct {
+		Links appstoreconnect.PagedDocumentLinks `json:"links"`
+	}
+	if err := json.Unmarshal(body, &page); err != nil {
+		return fmt.Errorf("failed to parse page of %s: %s", p.endpoint, err)
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("failed to parse page of %s: %s", p.endpoint, err)
+	}
+
+	cursor, err := nextPageCursor(page.Links.Next)
+	if err != nil {
+		return err
+	}
+	p.started = true
+	p.cursor = cursor
+
+	return nil
+}
+
+// nextPageCursor returns the cursor of the next page link, it is empty on the last page.
+func nextPageCursor(next string) (string, error) {
+	if next == "" {
+		return "", nil
+	}
+
+	u, err := url.Parse(next)
+	if err != nil {
+		return "", fmt.Errorf("invalid next page link (%s): %s", next, err)
+	}
+	cursor := u.Query().Get("cursor")
+	if cursor == "" {
+		return "", fmt.Errorf("invalid next page link (%s): cursor missing", next)
+	}
+	return cursor, nil
+}
+
+// pagedDevPortalClient lists the Developer Portal resources with the Pager, maxPageLimit resources per request,
+// instead of the 20 of appstoreconnectclient: a team with 100 devices needs a single request per profile check.
+type pagedDevPortalClient struct {
+	autocodesign.DevPortalClient
+
+	client *appstoreconnect.Client
+}
+
+func newPagedDevPortalClient(devPortalClient autocodesign.DevPortalClient, client *appstoreconnect.Client) *pagedDevPortalClient {
+	return &pagedDevPortalClient{
+		DevPortalClient: devPortalClient,
+		client:          client,
+	}
+}
+
+// QueryAllIOSCertificates ...
+func (c *pagedDevPortalClient) QueryAllIOSCertificates() (map[appstoreconnect.CertificateType][]autocodesign.Certificate, error) {
+	typeToCertificates := map[appstoreconnect.CertificateType][]autocodesign.Certificate{}
+	for _, certificateType := range []appstoreconnect.CertificateType{appstoreconnect.Development, appstoreconnect.IOSDevelopment, appstoreconnect.Distribution, appstoreconnect.IOSDistribution} {
+		certificates, err := c.queryCertificatesByType(certificateType)
+		if err != nil {
+			return map[appstoreconnect.CertificateType][]autocodesign.Certificate{}, err
+		}
+		typeToCertificates[certificateType] = certificates
+	}
+
+	return typeToCertificates, nil
+}
+
+func (c *pagedDevPortalClient) queryCertificatesByType(certificateType appstoreconnect.CertificateType) ([]autocodesign.Certificate, error) {
+	pager := NewPager(c.client, "certificates", PagerOptions{
+		Filter: map[string]string{"certificateType": string(certificateType)},
+	})
+	var certificates []autocodesign.Certificate
+	for pager.HasNext() {
+		var response appstoreconnect.CertificatesResponse
+		if err := pager.Next(&response); err != nil {
+			return nil, err
+		}
+
+		for _, certificate := range response.Data {
+			if certificate.Type != "certificates" {
+				continue
+			}
+
+			cert, err := x509.ParseCertificate(certificate.Attributes.CertificateContent)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse certificate: %s", err)
+			}
+
+			certificates = append(certificates, autocodesign.Certificate{
+				CertificateInfo: certificateutil.NewCertificateInfo(*cert, nil),
+				ID:              certificate.ID,
+			})
+		}
+	}
+	return certificates, nil
+}
+
+// ListDevices ...
+func (c *pagedDevPortalClient) ListDevices(udid string, platform appstoreconnect.DevicePlatform) ([]appstoreconnect.Device, error) {
+	pager := NewPager(c.client, "devices", PagerOptions{
+		Filter: map[string]string{
+			"udid":     udid,
+			"platform": string(platform),
+			"status":   string(appstoreconnect.Enabled),
+		},
+	})
+	var devices []appstoreconnect.Device
+	for pager.HasNext() {
+		var response appstoreconnect.DevicesResponse
+		if err := pager.Next(&response); err != nil {
+			return nil, err
+		}
+
+		devices = append(devices, response.Data...)
+	}
+	return devices, nil
+}
+
+// FindBundleID ...
+func (c *pagedDevPortalClient) FindBundleID(bundleIDIdentifier string) (*appstoreconnect.BundleID, error) {
+	pager := NewPager(c.client, "bundleIds", PagerOptions{
+		Filter: map[string]string{"identifier": bundleIDIdentifier},
+	})
+	for pager.HasNext() {
+		var response appstoreconnect.BundleIdsResponse
+		if err := pager.Next(&response); err != nil {
+			return nil, err
+		}
+
+		// The identifier filter matches the identifiers containing it, not only the exact match
+		for i, bundleID := range response.Data {
+			if bundleID.Attributes.Identifier == bundleIDIdentifier {
+				return &response.Data[i], nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// FindProfile ...
+func (c *pagedDevPortalClient) FindProfile(name string, profileType appstoreconnect.ProfileType) (autocodesign.Profile, error) {
+	profile, err := c.DevPortalClient.FindProfile(name, profileType)
+	if err != nil || profile == nil {
+		return nil, err
+	}
+	return newPagedProfile(profile, c.client), nil
+}
+
+// CreateProfile ...
+func (c *pagedDevPortalClient) CreateProfile(name string, profileType appstoreconnect.ProfileType, bundleID appstoreconnect.BundleID, certificateIDs []string, deviceIDs []string) (autocodesign.Profile, error) {
+	profile, err := c.DevPortalClient.CreateProfile(name, profileType, bundleID, certificateIDs, deviceIDs)
+	if err != nil {
+		return nil, err
+	}
+	return newPagedProfile(profile, c.client), nil
+}
+
+// pagedProfile lists the profile's certificates and devices with the Pager.
+type pagedProfile struct {
+	autocodesign.Profile
+
+	client *appstoreconnect.Client
+}
+
+func newPagedProfile(profile autocodesign.Profile, client *appstoreconnect.Client) pagedProfile {
+	return pagedProfile{
+		Profile: profile,
+		client:  client,
+	}
+}
+
+// CertificateIDs ...
+func (p pagedProfile) CertificateIDs() ([]string, error) {
+	ids, err := p.relationshipIDs("certificates")
+	if err != nil {
+		// The embedded profile reports the deleted profile as autocodesign.ProfilesInconsistentError
+		return p.Profile.CertificateIDs()
+	}
+	return ids, nil
+}
+
+// DeviceIDs ...
+func (p pagedProfile) DeviceIDs() ([]string, error) {
+	ids, err := p.relationshipIDs("devices")
+	if err != nil {
+		// The embedded profile reports the deleted profile as autocodesign.ProfilesInconsistentError
+		return p.Profile.DeviceIDs()
+	}
+	return ids, nil
+}
+
+func (p pagedProfile) relationshipIDs(relationship string) ([]string, error) {
+	pager := NewPager(p.client, "profiles/"+p.ID()+"/"+relationship, PagerOptions{})
+	ids := []string{}
+	for pager.HasNext() {
+		var response struct {
+			Data []struct {
+				ID string `json:"id"`
+			} `json:"data"`
+		}
+		if err := pager.Next(&response); err != nil {
+			return nil, err
+		}
+
+		for _, resource := range response.Data {
+			ids = append(ids, resource.ID)
+		}
+	}
+	return ids, nil
+}
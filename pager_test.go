@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/go-xcode/appleauth"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/devportalclient/appstoreconnect"
+	"github.com/stretchr/testify/assert"
+)
+
+func newFakeAPIClient(t *testing.T, server *fakeAppStoreConnect) *appstoreconnect.Client {
+	_, apiClient, err := createClient(
+		context.Background(),
+		[]appleauth.Source{&appleauth.InputAPIKeySource{}},
+		appleauth.Inputs{APIIssuer: "fake-issuer", APIKeyPath: "file://" + writeFakeAPIKey(t)},
+		"", nil, apiClientOptions{BaseURL: server.URL},
+	)
+	assert.NoError(t, err)
+	return apiClient
+}
+
+func TestPager_FollowsNextPageLink(t *testing.T) {
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.Path+"?"+r.URL.RawQuery)
+
+		next := ""
+		if r.URL.Query().Get("cursor") == "" {
+			next = appStoreConnectAPIURL + "devices?cursor=Mg&limit=200"
+		}
+		_, err := fmt.Fprintf(w, `{"data":[{"type":"devices","id":"device-%d"}],"links":{"next":"%s"}}`, len(queries), next)
+		assert.NoError(t, err)
+	}))
+	defer server.Close()
+
+	pager := NewPager(newTestAPIClient(t, server), "devices", PagerOptions{
+		Filter:  map[string]string{"udid": "00008030-000A", "platform": ""},
+		Include: []string{"bundleId", "devices"},
+	})
+
+	var ids []string
+	for pager.HasNext() {
+		var response appstoreconnect.DevicesResponse
+		assert.NoError(t, pager.Next(&response))
+		for _, device := range response.Data {
+			ids = append(ids, device.ID)
+		}
+	}
+
+	assert.Equal(t, []string{"device-1", "device-2"}, ids)
+	assert.Equal(t, []string{
+		"/v1/devices?filter%5Budid%5D=00008030-000A&include=bundleId%2Cdevices&limit=200",
+		"/v1/devices?cursor=Mg&filter%5Budid%5D=00008030-000A&include=bundleId%2Cdevices&limit=200",
+	}, queries, "the next page is requested from the base URL")
+	assert.EqualError(t, pager.Next(&appstoreconnect.DevicesResponse{}), "no more pages")
+}
+
+func TestPager_StopsEarly(t *testing.T) {
+	server := newFakeAppStoreConnect(t)
+	server.PageSize = 2
+	for _, udid := range []string{"00008030-000A", "00008030-000B", "00008030-000C", "00008030-000D", "00008030-000E"} {
+		server.AddDevice(udid, appstoreconnect.Iphone)
+	}
+
+	pager := NewPager(newFakeAPIClient(t, server), "devices", PagerOptions{})
+	var response appstoreconnect.DevicesResponse
+	assert.NoError(t, pager.Next(&response))
+
+	assert.Len(t, response.Data, 2)
+	assert.True(t, pager.HasNext())
+	assert.Equal(t, []string{"GET /v1/devices"}, server.Requests())
+}
+
+func TestPagedDevPortalClient_ProfileDeviceIDs(t *testing.T) {
+	identity := newTestIdentity(t, "Apple Development: Bitrise Bot")
+	server := newFakeAppStoreConnect(t)
+	certificateID := server.AddCertificate(appstoreconnect.IOSDevelopment, identity)
+	var deviceIDs []string
+	for i := 0; i < 100; i++ {
+		deviceIDs = append(deviceIDs, server.AddDevice(fmt.Sprintf("00008030-%04d", i), appstoreconnect.Iphone))
+	}
+	profileID := server.AddProfile(fakeProfile{
+		Name:           testProfileName,
+		Type:           appstoreconnect.IOSAppDevelopment,
+		BundleIDID:     server.AddBundleID("io.bitrise.app"),
+		CertificateIDs: []string{certificateID},
+		DeviceIDs:      deviceIDs,
+		Expiry:         time.Now().AddDate(1, 0, 0),
+	})
+
+	client := newFakeDevPortalClient(t, server, "")
+	profile, err := client.FindProfile(testProfileName, appstoreconnect.IOSAppDevelopment)
+	assert.NoError(t, err)
+	if !assert.NotNil(t, profile) {
+		return
+	}
+
+	ids, err := profile.DeviceIDs()
+	assert.NoError(t, err)
+	assert.Equal(t, deviceIDs, ids)
+
+	devices, err := client.ListDevices("", appstoreconnect.IOSDevice)
+	assert.NoError(t, err)
+	assert.Len(t, devices, 100)
+
+	assert.Equal(t, []string{
+		"GET /v1/profiles",
+		"GET /v1/profiles/" + profileID + "/devices",
+		"GET /v1/devices",
+	}, server.Requests(), "100 devices are listed by a single request")
+}
+
+func Test_nextPageCursor(t *testing.T) {
+	cursor, err := nextPageCursor("")
+	assert.NoError(t, err)
+	assert.Equal(t, "", cursor)
+
+	cursor, err = nextPageCursor(appStoreConnectAPIURL + "profiles?cursor=Mg&limit=200")
+	assert.NoError(t, err)
+	assert.Equal(t, "Mg", cursor)
+
+	_, err = nextPageCursor(appStoreConnectAPIURL + "profiles?limit=200")
+	assert.EqualError(t, err, "invalid next page link (https://api.appstoreconnect.apple.com/v1/profiles?limit=200): cursor missing")
+}
+
+func TestPagedProfile_DeletedProfile(t *testing.T) {
+	identity := newTestIdentity(t, "Apple Development: Bitrise Bot")
+	server := newFakeAppStoreConnect(t)
+	certificateID := server.AddCertificate(appstoreconnect.IOSDevelopment, identity)
+	server.AddProfile(fakeProfile{
+		Name:           testProfileName,
+		Type:           appstoreconnect.IOSAppDevelopment,
+		BundleIDID:     server.AddBundleID("io.bitrise.app"),
+		CertificateIDs: []string{certificateID},
+		Expiry:         time.Now().AddDate(1, 0, 0),
+	})
+
+	client := newFakeDevPortalClient(t, server, "")
+	profile, err := client.FindProfile(testProfileName, appstoreconnect.IOSAppDevelopment)
+	assert.NoError(t, err)
+	if !assert.NotNil(t, profile) {
+		return
+	}
+	assert.NoError(t, client.DeleteProfile(profile.ID()))
+
+	_, err = profile.DeviceIDs()
+	assert.True(t, errors.As(err, &autocodesign.ProfilesInconsistentError{}), "unexpected error: %v", err)
+	_, err = profile.CertificateIDs()
+	assert.True(t, errors.As(err, &autocodesign.ProfilesInconsistentError{}), "unexpected error: %v", err)
+}
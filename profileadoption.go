@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/sliceutil"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/devportalclient/appstoreconnect"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/devportalclient/appstoreconnectclient"
+)
+
+// profileAdoptingDevPortalClient reuses an existing profile of the bundle ID, regardless of its name,
+// instead of creating a new Bitrise managed profile, if the existing profile passes the same checks
+// as the Bitrise managed profiles (validity, entitlements, certificates and devices).
+type profileAdoptingDevPortalClient struct {
+	autocodesign.DevPortalClient
+
+	listProfiles        func(bundleID appstoreconnect.BundleID) ([]autocodesign.Profile, error)
+	minProfileDaysValid int
+
+	// entitlementsByBundleID holds the project entitlements autocodesign checked the app IDs against, by app ID ID.
+	entitlementsByBundleID map[string]autocodesign.Entitlements
+}
+
+func newProfileAdoptingDevPortalClient(client autocodesign.DevPortalClient, apiClient *appstoreconnect.Client, minProfileDaysValid int) *profileAdoptingDevPortalClient {
+	return &profileAdoptingDevPortalClient{
+		DevPortalClient: client,
+		listProfiles: func(bundleID appstoreconnect.BundleID) ([]autocodesign.Profile, error) {
+			return listBundleIDProfiles(apiClient, bundleID)
+		},
+		minProfileDaysValid:    minProfileDaysValid,
+		entitlementsByBundleID: map[string]autocodesign.Entitlements{},
+	}
+}
+
+// CheckBundleIDEntitlements ...
+func (c *profileAdoptingDevPortalClient) CheckBundleIDEntitlements(bundleID appstoreconnect.BundleID, appEntitlements autocodesign.Entitlements) error {
+	c.entitlementsByBundleID[bundleID.ID] = appEntitlements
+	return c.DevPortalClient.CheckBundleIDEntitlements(bundleID, appEntitlements)
+}
+
+// SyncBundleID ...
+func (c *profileAdoptingDevPortalClient) SyncBundleID(bundleID appstoreconnect.BundleID, appEntitlements autocodesign.Entitlements) error {
+	c.entitlementsByBundleID[bundleID.ID] = appEntitlements
+	return c.DevPortalClient.SyncBundleID(bundleID, appEntitlements)
+}
+
+// CreateProfile ...
+func (c *profileAdoptingDevPortalClient) CreateProfile(name string, profileType appstoreconnect.ProfileType, bundleID appstoreconnect.BundleID, certificateIDs []string, deviceIDs []string) (autocodesign.Profile, error) {
+	profile, err := c.findAdoptableProfile(name, profileType, bundleID, certificateIDs, deviceIDs)
+	if err != nil {
+		return nil, err
+	}
+	if profile != nil {
+		log.Donef("  existing profile adopted: %s ID: %s UUID: %s Expiry: %s", profile.Attributes().Name, profile.ID(), profile.Attributes().UUID, time.Time(profile.Attributes().ExpirationDate))
+		return profile, nil
+	}
+
+	return c.DevPortalClient.CreateProfile(name, profileType, bundleID, certificateIDs, deviceIDs)
+}
+
+func (c *profileAdoptingDevPortalClient) findAdoptableProfile(name string, profileType appstoreconnect.ProfileType, bundleID appstoreconnect.BundleID, certificateIDs []string, deviceIDs []string) (autocodesign.Profile, error) {
+	entitlements, ok := c.entitlementsByBundleID[bundleID.ID]
+	if !ok {
+		// The app ID's entitlements were neither checked nor synced, the profiles can not be checked against them
+		return nil, nil
+	}
+
+	profiles, err := c.listProfiles(bundleID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list profiles of %s: %s", bundleID.Attributes.Identifier, err)
+	}
+
+	for _, profile := range profiles {
+		attributes := profile.Attributes()
+		// The Bitrise managed profile with the same name has already been checked (and deleted)
+		if attributes.Name == name || attributes.ProfileType != profileType || attributes.ProfileState != appstoreconnect.Active {
+			continue
+		}
+
+		reason, err := c.checkProfile(profile, entitlements, certificateIDs, deviceIDs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check profile %s: %s", attributes.Name, err)
+		}
+		if reason != "" {
+			log.Debugf("  profile %s can not be adopted: %s", attributes.Name, reason)
+			continue
+		}
+
+		return profile, nil
+	}
+
+	return nil, nil
+}
+
+// checkProfile returns the reason why the profile doesn't match the project requirements, see autocodesign.checkProfile.
+// The app ID capabilities are not checked, as autocodesign has already synchronized them.
+func (c *profileAdoptingDevPortalClient) checkProfile(profile autocodesign.Profile, entitlements autocodesign.Entitlements, certificateIDs, deviceIDs []string) (string, error) {
+	minExpiry := time.Now().Add(time.Duration(c.minProfileDaysValid) * 24 * time.Hour)
+	if time.Time(profile.Attributes().ExpirationDate).Before(minExpiry) {
+		return fmt.Sprintf("profile expired, or will expire in less then %d day(s)", c.minProfileDaysValid), nil
+	}
+
+	profileEntitlements, err := profile.Entitlements()
+	if err != nil {
+		return "", err
+	}
+	missingContainers, err := autocodesign.FindMissingContainers(entitlements, profileEntitlements)
+	if err != nil {
+		return "", err
+	}
+	if len(missingContainers) > 0 {
+		return fmt.Sprintf("project uses containers that are missing from the provisioning profile: %v", missingContainers), nil
+	}
+
+	profileCertificateIDs, err := profile.CertificateIDs()
+	if err != nil {
+		return "", err
+	}
+	for _, id := range certificateIDs {
+		if !sliceutil.IsStringInSlice(id, profileCertificateIDs) {
+			return fmt.Sprintf("certificate with ID (%s) not included in the profile", id), nil
+		}
+	}
+
+	profileDeviceIDs, err := profile.DeviceIDs()
+	if err != nil {
+		return "", err
+	}
+	for _, id := range deviceIDs {
+		if !sliceutil.IsStringInSlice(id, profileDeviceIDs) {
+			return fmt.Sprintf("device with ID (%s) not included in the profile", id), nil
+		}
+	}
+
+	return "", nil
+}
+
+func listBundleIDProfiles(client *appstoreconnect.Client, bundleID appstoreconnect.BundleID) ([]autocodesign.Profile, error) {
+	relationshipLink := bundleID.Relationships.Profiles.Links.Related
+	if relationshipLink == "" {
+		return nil, nil
+	}
+
+	pager := NewPager(client, relationshipLink, PagerOptions{})
+	var profiles []autocodesign.Profile
+	for pager.HasNext() {
+		var response appstoreconnect.ProfilesResponse
+		if err := pager.Next(&response); err != nil {
+			return nil, err
+		}
+
+		for i := range response.Data {
+			profiles = append(profiles, appstoreconnectclient.NewAPIProfile(client, &response.Data[i]))
+		}
+	}
+	return profiles, nil
+}
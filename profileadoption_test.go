@@ -0,0 +1,123 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/go-xcode/v2/autocodesign"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/devportalclient/appstoreconnect"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProfileAdoptingDevPortalClient_CreateProfile(t *testing.T) {
+	bundleID := appstoreconnect.BundleID{ID: "bundle-id", Attributes: appstoreconnect.BundleIDAttributes{Identifier: "io.bitrise.app"}}
+	validUntil := appstoreconnect.Time(time.Now().AddDate(0, 6, 0))
+	profile := func(name string, profileType appstoreconnect.ProfileType, state appstoreconnect.ProfileState, expiry appstoreconnect.Time, certificateIDs, deviceIDs []string) autocodesign.Profile {
+		return plannedProfile{
+			attributes: appstoreconnect.ProfileAttributes{
+				Name:           name,
+				ProfileType:    profileType,
+				ProfileState:   state,
+				ExpirationDate: expiry,
+			},
+			bundleID:       bundleID,
+			certificateIDs: certificateIDs,
+			deviceIDs:      deviceIDs,
+		}
+	}
+
+	tests := []struct {
+		name        string
+		profiles    []autocodesign.Profile
+		wantAdopted string
+	}{
+		{
+			name: "adopts matching profile",
+			profiles: []autocodesign.Profile{
+				profile("match Development io.bitrise.app", appstoreconnect.IOSAppDevelopment, appstoreconnect.Active, validUntil, []string{"cert", "other-cert"}, []string{"device-1", "device-2"}),
+			},
+			wantAdopted: "match Development io.bitrise.app",
+		},
+		{
+			name: "skips profiles not matching the requirements",
+			profiles: []autocodesign.Profile{
+				profile("app store", appstoreconnect.IOSAppStore, appstoreconnect.Active, validUntil, []string{"cert"}, []string{"device-1"}),
+				profile("invalid", appstoreconnect.IOSAppDevelopment, appstoreconnect.Invalid, validUntil, []string{"cert"}, []string{"device-1"}),
+				profile("expiring", appstoreconnect.IOSAppDevelopment, appstoreconnect.Active, appstoreconnect.Time(time.Now().AddDate(0, 0, 1)), []string{"cert"}, []string{"device-1"}),
+				profile("other certificate", appstoreconnect.IOSAppDevelopment, appstoreconnect.Active, validUntil, []string{"other-cert"}, []string{"device-1"}),
+				profile("missing device", appstoreconnect.IOSAppDevelopment, appstoreconnect.Active, validUntil, []string{"cert"}, nil),
+				profile("Bitrise iOS development - (io.bitrise.app)", appstoreconnect.IOSAppDevelopment, appstoreconnect.Active, validUntil, []string{"cert"}, []string{"device-1"}),
+			},
+			wantAdopted: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			created := plannedProfile{attributes: appstoreconnect.ProfileAttributes{Name: "Bitrise iOS development - (io.bitrise.app)"}}
+			mockClient := new(autocodesign.MockDevPortalClient)
+			mockClient.On("CheckBundleIDEntitlements", bundleID, autocodesign.Entitlements{}).Return(nil)
+			if tt.wantAdopted == "" {
+				mockClient.On("CreateProfile", created.attributes.Name, appstoreconnect.IOSAppDevelopment, bundleID, []string{"cert"}, []string{"device-1"}).Return(created, nil).Once()
+			}
+
+			client := &profileAdoptingDevPortalClient{
+				DevPortalClient: mockClient,
+				listProfiles: func(appstoreconnect.BundleID) ([]autocodesign.Profile, error) {
+					return tt.profiles, nil
+				},
+				minProfileDaysValid:    30,
+				entitlementsByBundleID: map[string]autocodesign.Entitlements{},
+			}
+
+			assert.NoError(t, client.CheckBundleIDEntitlements(bundleID, autocodesign.Entitlements{}))
+			got, err := client.CreateProfile(created.attributes.Name, appstoreconnect.IOSAppDevelopment, bundleID, []string{"cert"}, []string{"device-1"})
+			assert.NoError(t, err)
+
+			if tt.wantAdopted != "" {
+				assert.Equal(t, tt.wantAdopted, got.Attributes().Name)
+			} else {
+				assert.Equal(t, created, got)
+			}
+			mockClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestProfileAdoptingDevPortalClient_DryRun(t *testing.T) {
+	bundleID := appstoreconnect.BundleID{ID: "bundle-id", Attributes: appstoreconnect.BundleIDAttributes{Identifier: "io.bitrise.app"}}
+	existing := plannedProfile{
+		attributes: appstoreconnect.ProfileAttributes{
+			Name:           "match Development io.bitrise.app",
+			ProfileType:    appstoreconnect.IOSAppDevelopment,
+			ProfileState:   appstoreconnect.Active,
+			ExpirationDate: appstoreconnect.Time(time.Now().AddDate(0, 6, 0)),
+		},
+		bundleID:       bundleID,
+		certificateIDs: []string{"cert"},
+		deviceIDs:      []string{"device-1"},
+	}
+
+	mockClient := new(autocodesign.MockDevPortalClient)
+	mockClient.On("CheckBundleIDEntitlements", bundleID, autocodesign.Entitlements{}).Return(nil)
+	plan := &PortalPlan{}
+	client := &profileAdoptingDevPortalClient{
+		DevPortalClient: newDryRunDevPortalClient(mockClient, plan, nil),
+		listProfiles: func(appstoreconnect.BundleID) ([]autocodesign.Profile, error) {
+			return []autocodesign.Profile{existing}, nil
+		},
+		minProfileDaysValid:    30,
+		entitlementsByBundleID: map[string]autocodesign.Entitlements{},
+	}
+
+	assert.NoError(t, client.CheckBundleIDEntitlements(bundleID, autocodesign.Entitlements{}))
+	got, err := client.CreateProfile("Bitrise iOS development - (io.bitrise.app)", appstoreconnect.IOSAppDevelopment, bundleID, []string{"cert"}, []string{"device-1"})
+	assert.NoError(t, err)
+	assert.Equal(t, existing, got)
+	assert.Empty(t, plan.Changes, "the adopted profile is not planned to be created")
+
+	_, err = client.CreateProfile("Bitrise iOS development - (io.bitrise.app)", appstoreconnect.IOSAppDevelopment, bundleID, []string{"other-cert"}, []string{"device-1"})
+	assert.NoError(t, err)
+	if assert.Len(t, plan.Changes, 1) {
+		assert.Equal(t, CreateProfileAction, plan.Changes[0].Action)
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/bitrise-io/go-xcode/profileutil"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/devportalclient/appstoreconnect"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/devportalclient/appstoreconnectclient"
+)
+
+// defaultProfileNamePattern matches the profile names generated by autocodesign (see autocodesign.profileName),
+// for example: `Bitrise iOS development - (io.bitrise.app)` or `Wildcard Bitrise iOS development - (io.bitrise)`.
+var defaultProfileNamePattern = regexp.MustCompile(`^(Wildcard )?Bitrise \S+ \S+ - \((.+)\)$`)
+
+// ProfileNameParams are the fields available in the profile_name_template input
+type ProfileNameParams struct {
+	Platform     autocodesign.Platform
+	Distribution autocodesign.DistributionType
+	// BundleID of the profile, without the `.*` suffix for wildcard profiles.
+	BundleID string
+	Wildcard bool
+	TeamID   string
+	Suffix   string
+}
+
+// ProfileNameTemplate renders provisioning profile names from a Go template
+type ProfileNameTemplate struct {
+	tmpl *template.Template
+}
+
+// NewProfileNameTemplate parses the template and checks it against sample parameters.
+func NewProfileNameTemplate(text string) (ProfileNameTemplate, error) {
+	tmpl, err := template.New("profile_name_template").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return ProfileNameTemplate{}, fmt.Errorf("invalid profile name template: %s", err)
+	}
+
+	t := ProfileNameTemplate{tmpl: tmpl}
+	if _, err := t.Render(ProfileNameParams{
+		Platform:     autocodesign.IOS,
+		Distribution: autocodesign.Development,
+		BundleID:     "io.bitrise.app",
+		TeamID:       "TEAMID",
+	}); err != nil {
+		return ProfileNameTemplate{}, err
+	}
+
+	return t, nil
+}
+
+// Render ...
+func (t ProfileNameTemplate) Render(params ProfileNameParams) (string, error) {
+	var b bytes.Buffer
+	if err := t.tmpl.Execute(&b, params); err != nil {
+		return "", fmt.Errorf("failed to render profile name template: %s", err)
+	}
+
+	name := strings.TrimSpace(b.String())
+	if name == "" {
+		return "", fmt.Errorf("profile name template rendered an empty name for %s", params.BundleID)
+	}
+	return name, nil
+}
+
+// profileNamingDevPortalClient replaces the profile names generated by autocodesign
+// with the ones rendered from the profile name template.
+type profileNamingDevPortalClient struct {
+	autocodesign.DevPortalClient
+
+	template ProfileNameTemplate
+	teamID   string
+	suffix   string
+}
+
+func newProfileNamingDevPortalClient(client autocodesign.DevPortalClient, template ProfileNameTemplate, teamID, suffix string) *profileNamingDevPortalClient {
+	return &profileNamingDevPortalClient{
+		DevPortalClient: client,
+		template:        template,
+		teamID:          teamID,
+		suffix:          suffix,
+	}
+}
+
+// QueryCertificateBySerial ...
+func (c *profileNamingDevPortalClient) QueryCertificateBySerial(serial big.Int) (autocodesign.Certificate, error) {
+	cert, err := c.DevPortalClient.QueryCertificateBySerial(serial)
+	if err == nil && c.teamID == "" {
+		// The team ID is not known in advance with API key authentication
+		c.teamID = cert.CertificateInfo.TeamID
+	}
+	return cert, err
+}
+
+// FindProfile ...
+func (c *profileNamingDevPortalClient) FindProfile(name string, profileType appstoreconnect.ProfileType) (autocodesign.Profile, error) {
+	name, err := c.profileName(name, profileType)
+	if err != nil {
+		return nil, err
+	}
+	return c.DevPortalClient.FindProfile(name, profileType)
+}
+
+// CreateProfile ...
+func (c *profileNamingDevPortalClient) CreateProfile(name string, profileType appstoreconnect.ProfileType, bundleID appstoreconnect.BundleID, certificateIDs []string, deviceIDs []string) (autocodesign.Profile, error) {
+	name, err := c.profileName(name, profileType)
+	if err != nil {
+		return nil, err
+	}
+	return c.DevPortalClient.CreateProfile(name, profileType, bundleID, certificateIDs, deviceIDs)
+}
+
+// profileName renders the template for autocodesign generated profile names, other names are returned as is.
+func (c *profileNamingDevPortalClient) profileName(name string, profileType appstoreconnect.ProfileType) (string, error) {
+	match := defaultProfileNamePattern.FindStringSubmatch(name)
+	if match == nil {
+		return name, nil
+	}
+
+	return c.template.Render(ProfileNameParams{
+		Platform:     profileTypePlatform(profileType),
+		Distribution: profileTypeDistribution(profileType),
+		BundleID:     match[2],
+		Wildcard:     match[1] != "",
+		TeamID:       c.teamID,
+		Suffix:       c.suffix,
+	})
+}
+
+// BitriseProfile is a Bitrise managed profile on the Developer Portal
+type BitriseProfile struct {
+	appstoreconnect.Profile
+
+	// BundleID of the profile, with the `.*` suffix for wildcard profiles.
+	BundleID string
+}
+
+// BitriseProfileMatcher recognizes the Bitrise managed profiles: the ones with the default names,
+// and if a profile name template is set, the ones named by the template for a Bitrise managed app ID (see bitriseAppIDName).
+type BitriseProfileMatcher struct {
+	client   *appstoreconnect.Client
+	template *ProfileNameTemplate
+	teamID   string
+	suffix   string
+}
+
+// NewBitriseProfileMatcher ...
+func NewBitriseProfileMatcher(client *appstoreconnect.Client, template *ProfileNameTemplate, teamID, suffix string) BitriseProfileMatcher {
+	return BitriseProfileMatcher{
+		client:   client,
+		template: template,
+		teamID:   teamID,
+		suffix:   suffix,
+	}
+}
+
+// ListProfiles returns the Bitrise managed profiles of the team.
+func (m BitriseProfileMatcher) ListProfiles() ([]BitriseProfile, error) {
+	pager := NewPager(m.client, "profiles", PagerOptions{})
+	var profiles []BitriseProfile
+	for pager.HasNext() {
+		var response appstoreconnect.ProfilesResponse
+		if err := pager.Next(&response); err != nil {
+			return nil, fmt.Errorf("failed to list profiles: %s", err)
+		}
+
+		for _, profile := range response.Data {
+			bundleID, ok, err := m.BundleID(profile)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				profiles = append(profiles, BitriseProfile{Profile: profile, BundleID: bundleID})
+			}
+		}
+	}
+	return profiles, nil
+}
+
+// BundleID returns the bundle ID of a Bitrise managed profile, ok is false for the other profiles.
+// The bundle ID of a templated profile name is queried from the Developer Portal.
+func (m BitriseProfileMatcher) BundleID(profile appstoreconnect.Profile) (string, bool, error) {
+	name := profile.Attributes.Name
+	if match := defaultProfileNamePattern.FindStringSubmatch(name); match != nil {
+		bundleID := match[2]
+		if match[1] != "" {
+			bundleID += ".*"
+		}
+		return bundleID, true, nil
+	}
+	if m.template == nil {
+		return "", false, nil
+	}
+
+	bundleID, err := appstoreconnectclient.NewAPIProfile(m.client, &profile).BundleID()
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get bundle ID of profile %s: %s", name, err)
+	}
+	identifier := bundleID.Attributes.Identifier
+	if bundleID.Attributes.Name != bitriseAppIDName(identifier) {
+		return "", false, nil
+	}
+
+	teamID := m.teamID
+	if teamID == "" {
+		// The team ID is not known in advance with API key authentication
+		if teamID, err = profileTeamID(profile); err != nil {
+			return "", false, fmt.Errorf("failed to read team ID of profile %s: %s", name, err)
+		}
+	}
+
+	templateName, err := m.template.Render(ProfileNameParams{
+		Platform:     profileTypePlatform(profile.Attributes.ProfileType),
+		Distribution: profileTypeDistribution(profile.Attributes.ProfileType),
+		BundleID:     strings.TrimSuffix(identifier, ".*"),
+		Wildcard:     strings.HasSuffix(identifier, ".*"),
+		TeamID:       teamID,
+		Suffix:       m.suffix,
+	})
+	if err != nil {
+		return "", false, err
+	}
+	return identifier, templateName == name, nil
+}
+
+func profileTeamID(profile appstoreconnect.Profile) (string, error) {
+	pkcs7, err := profileutil.ProvisioningProfileFromContent(profile.Attributes.ProfileContent)
+	if err != nil {
+		return "", err
+	}
+	info, err := profileutil.NewProvisioningProfileInfo(*pkcs7)
+	if err != nil {
+		return "", err
+	}
+	return info.TeamID, nil
+}
+
+// bitriseAppIDName returns the name of the app IDs created by the Step, the same as autocodesign's (see autocodesign.appIDName).
+// The prune mode recognizes the Bitrise managed app IDs by this name.
+func bitriseAppIDName(bundleID string) string {
+	prefix := ""
+	if strings.HasSuffix(bundleID, ".*") {
+		prefix = "Wildcard "
+	}
+	r := strings.NewReplacer(".", " ", "_", " ", "-", " ", "*", " ")
+	return prefix + "Bitrise " + r.Replace(bundleID)
+}
+
+// appIDNamingDevPortalClient creates the app IDs with the bitriseAppIDName names,
+// so that the prune mode finds every app ID created by the Step.
+type appIDNamingDevPortalClient struct {
+	autocodesign.DevPortalClient
+}
+
+func newAppIDNamingDevPortalClient(client autocodesign.DevPortalClient) *appIDNamingDevPortalClient {
+	return &appIDNamingDevPortalClient{DevPortalClient: client}
+}
+
+// CreateBundleID ...
+func (c *appIDNamingDevPortalClient) CreateBundleID(bundleIDIdentifier, _ string) (*appstoreconnect.BundleID, error) {
+	return c.DevPortalClient.CreateBundleID(bundleIDIdentifier, bitriseAppIDName(bundleIDIdentifier))
+}
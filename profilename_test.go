@@ -0,0 +1,101 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/bitrise-io/go-xcode/v2/autocodesign"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/devportalclient/appstoreconnect"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewProfileNameTemplate(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		wantErr bool
+	}{
+		{name: "valid", text: "CI {{.Platform}} {{.Distribution}} {{.BundleID}}", wantErr: false},
+		{name: "syntax error", text: "CI {{.Platform", wantErr: true},
+		{name: "unknown field", text: "CI {{.Branch}}", wantErr: true},
+		{name: "empty name", text: "{{if .Wildcard}}Wildcard{{end}}", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewProfileNameTemplate(tt.text)
+			assert.Equal(t, tt.wantErr, err != nil, err)
+		})
+	}
+}
+
+func TestProfileNamingDevPortalClient(t *testing.T) {
+	tmpl, err := NewProfileNameTemplate(`{{if .Wildcard}}Wildcard {{end}}CI {{.Platform}} {{.Distribution}} {{.BundleID}} {{.TeamID}}{{with .Suffix}} {{.}}{{end}}`)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		profileName string
+		profileType appstoreconnect.ProfileType
+		want        string
+	}{
+		{
+			name:        "default name",
+			profileName: "Bitrise iOS development - (io.bitrise.app)",
+			profileType: appstoreconnect.IOSAppDevelopment,
+			want:        "CI iOS development io.bitrise.app TEAM feature",
+		},
+		{
+			name:        "default wildcard name",
+			profileName: "Wildcard Bitrise tvOS app-store - (io.bitrise)",
+			profileType: appstoreconnect.TvOSAppStore,
+			want:        "Wildcard CI tvOS app-store io.bitrise TEAM feature",
+		},
+		{
+			name:        "macOS name",
+			profileName: "Bitrise macOS development - (io.bitrise.app)",
+			profileType: appstoreconnect.MacAppDevelopment,
+			want:        "CI macOS development io.bitrise.app TEAM feature",
+		},
+		{
+			name:        "Mac Catalyst name",
+			profileName: "Bitrise macCatalyst app-store - (maccatalyst.io.bitrise.app)",
+			profileType: MacCatalystAppStore,
+			want:        "CI macCatalyst app-store maccatalyst.io.bitrise.app TEAM feature",
+		},
+		{
+			name:        "custom name",
+			profileName: "match Development io.bitrise.app",
+			profileType: appstoreconnect.IOSAppDevelopment,
+			want:        "match Development io.bitrise.app",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := new(autocodesign.MockDevPortalClient)
+			mockClient.On("FindProfile", tt.want, tt.profileType).Return(nil, nil).Once()
+			mockClient.On("CreateProfile", tt.want, tt.profileType, appstoreconnect.BundleID{}, []string{"cert"}, []string{"device"}).Return(nil, nil).Once()
+
+			client := newProfileNamingDevPortalClient(mockClient, tmpl, "TEAM", "feature")
+
+			_, err := client.FindProfile(tt.profileName, tt.profileType)
+			assert.NoError(t, err)
+			_, err = client.CreateProfile(tt.profileName, tt.profileType, appstoreconnect.BundleID{}, []string{"cert"}, []string{"device"})
+			assert.NoError(t, err)
+
+			mockClient.AssertExpectations(t)
+		})
+	}
+}
+
+func Test_bitriseAppIDName(t *testing.T) {
+	assert.Equal(t, "Bitrise io bitrise my app", bitriseAppIDName("io.bitrise.my-app"))
+	assert.Equal(t, "Wildcard Bitrise io bitrise  ", bitriseAppIDName("io.bitrise.*"))
+}
+
+func TestAppIDNamingDevPortalClient_CreateBundleID(t *testing.T) {
+	mockClient := new(autocodesign.MockDevPortalClient)
+	mockClient.On("CreateBundleID", "io.bitrise.my-app", "Bitrise io bitrise my app").Return(&appstoreconnect.BundleID{}, nil).Once()
+
+	_, err := newAppIDNamingDevPortalClient(mockClient).CreateBundleID("io.bitrise.my-app", "My App")
+	assert.NoError(t, err)
+	mockClient.AssertExpectations(t)
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/bitrise-io/go-steputils/input"
+	"github.com/bitrise-io/go-utils/filedownloader"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/pathutil"
+	"github.com/bitrise-io/go-xcode/profileutil"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/devportalclient/appstoreconnect"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/localcodesignasset"
+)
+
+// profileDirProvider is a localcodesignasset.ProvisioningProfileProvider and ProvisioningProfileConverter,
+// which reads the profiles from the given directories instead of only the MobileDevice directory.
+// A profile installed into multiple directories is listed once.
+type profileDirProvider struct {
+	dirs []string
+
+	pathByUUID map[string]string
+}
+
+func newProfileDirProvider(dirs []string) *profileDirProvider {
+	return &profileDirProvider{
+		dirs:       dirs,
+		pathByUUID: map[string]string{},
+	}
+}
+
+// ListProvisioningProfiles ...
+func (p *profileDirProvider) ListProvisioningProfiles() ([]profileutil.ProvisioningProfileInfoModel, error) {
+	var infos []profileutil.ProvisioningProfileInfoModel
+	pathByUUID := map[string]string{}
+	for _, dir := range p.dirs {
+		pths, err := filepath.Glob(filepath.Join(pathutil.EscapeGlobPath(dir), "*.mobileprovision"))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, pth := range pths {
+			info, err := profileutil.NewProvisioningProfileInfoFromFile(pth)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse provisioning profile (%s): %s", pth, err)
+			}
+			if _, ok := pathByUUID[info.UUID]; ok {
+				continue
+			}
+
+			pathByUUID[info.UUID] = pth
+			infos = append(infos, info)
+		}
+	}
+
+	p.pathByUUID = pathByUUID
+	return infos, nil
+}
+
+// ProfileInfoToProfile ...
+func (p *profileDirProvider) ProfileInfoToProfile(info profileutil.ProvisioningProfileInfoModel) (autocodesign.Profile, error) {
+	pth, ok := p.pathByUUID[info.UUID]
+	if !ok {
+		return nil, fmt.Errorf("provisioning profile (%s) not found", info.UUID)
+	}
+	content, err := ioutil.ReadFile(pth)
+	if err != nil {
+		return nil, err
+	}
+
+	return newFileProfile(info, content), nil
+}
+
+// profileDownloader is a localcodesignasset.ProvisioningProfileProvider, which lists the downloaded profiles
+// before the installed ones, so that the provided profiles are used instead of generating new ones.
+// It is a localcodesignasset.ProvisioningProfileConverter too, as the downloaded profiles are not installed.
+type profileDownloader struct {
+	ctx       context.Context
+	urls      []string
+	client    *http.Client
+	installed localcodesignasset.ProvisioningProfileProvider
+	converter localcodesignasset.ProvisioningProfileConverter
+
+	infos    []profileutil.ProvisioningProfileInfoModel
+	contents map[string][]byte
+}
+
+func newProfileDownloader(ctx context.Context, urls []string, client *http.Client, installed localcodesignasset.ProvisioningProfileProvider, converter localcodesignasset.ProvisioningProfileConverter) *profileDownloader {
+	return &profileDownloader{
+		ctx:       ctx,
+		urls:      urls,
+		client:    client,
+		installed: installed,
+		converter: converter,
+	}
+}
+
+// ListProvisioningProfiles ...
+func (d *profileDownloader) ListProvisioningProfiles() ([]profileutil.ProvisioningProfileInfoModel, error) {
+	if d.contents == nil {
+		if err := d.download(); err != nil {
+			return nil, err
+		}
+	}
+
+	installedInfos, err := d.installed.ListProvisioningProfiles()
+	if err != nil {
+		return nil, err
+	}
+
+	infos := append([]profileutil.ProvisioningProfileInfoModel{}, d.infos...)
+	for _, info := range installedInfos {
+		if _, ok := d.contents[info.UUID]; !ok {
+			infos = append(infos, info)
+		}
+	}
+	return infos, nil
+}
+
+// ProfileInfoToProfile ...
+func (d *profileDownloader) ProfileInfoToProfile(info profileutil.ProvisioningProfileInfoModel) (autocodesign.Profile, error) {
+	content, ok := d.contents[info.UUID]
+	if !ok {
+		return d.converter.ProfileInfoToProfile(info)
+	}
+
+	return newFileProfile(info, content), nil
+}
+
+func (d *profileDownloader) download() error {
+	ctx, cancel := context.WithTimeout(d.ctx, 5*time.Minute)
+	defer cancel()
+
+	fileProvider := input.NewFileProvider(filedownloader.NewWithContext(ctx, d.client))
+	contents := map[string][]byte{}
+	var infos []profileutil.ProvisioningProfileInfoModel
+	for i, url := range d.urls {
+		log.Debugf("Downloading provisioning profile number %d from %s", i, url)
+
+		content, err := fileProvider.Contents(url)
+		if err != nil {
+			return fmt.Errorf("failed to download provisioning profile (%s): %s", url, err)
+		} else if content == nil {
+			return fmt.Errorf("provisioning profile (%s) is empty", url)
+		}
+
+		pkcs7, err := profileutil.ProvisioningProfileFromContent(content)
+		if err != nil {
+			return fmt.Errorf("failed to parse provisioning profile (%s): %s", url, err)
+		}
+		info, err := profileutil.NewProvisioningProfileInfo(*pkcs7)
+		if err != nil {
+			return fmt.Errorf("failed to parse provisioning profile (%s): %s", url, err)
+		}
+
+		log.Printf("provisioning profile downloaded: %s (%s)", info.Name, info.UUID)
+		contents[info.UUID] = content
+		infos = append(infos, info)
+	}
+
+	d.contents = contents
+	d.infos = infos
+	return nil
+}
+
+func newFileProfile(info profileutil.ProvisioningProfileInfoModel, content []byte) fileProfile {
+	platform := appstoreconnect.IOS
+	if info.Type == profileutil.ProfileTypeMacOs {
+		platform = appstoreconnect.MacOS
+	}
+
+	return fileProfile{
+		attributes: appstoreconnect.ProfileAttributes{
+			Name:           info.Name,
+			UUID:           info.UUID,
+			ProfileContent: content,
+			Platform:       platform,
+			ExpirationDate: appstoreconnect.Time(info.ExpirationDate),
+		},
+		bundleID: info.BundleID,
+	}
+}
+
+// fileProfile is a provisioning profile read from a file, not from the Developer Portal
+type fileProfile struct {
+	attributes appstoreconnect.ProfileAttributes
+	bundleID   string
+}
+
+// ID ...
+func (p fileProfile) ID() string {
+	return ""
+}
+
+// Attributes ...
+func (p fileProfile) Attributes() appstoreconnect.ProfileAttributes {
+	return p.attributes
+}
+
+// CertificateIDs ...
+func (p fileProfile) CertificateIDs() ([]string, error) {
+	return nil, nil
+}
+
+// DeviceIDs ...
+func (p fileProfile) DeviceIDs() ([]string, error) {
+	return nil, nil
+}
+
+// BundleID ...
+func (p fileProfile) BundleID() (appstoreconnect.BundleID, error) {
+	return appstoreconnect.BundleID{
+		Attributes: appstoreconnect.BundleIDAttributes{
+			Identifier: p.bundleID,
+			Name:       p.attributes.Name,
+		},
+	}, nil
+}
+
+// Entitlements ...
+func (p fileProfile) Entitlements() (autocodesign.Entitlements, error) {
+	return autocodesign.ParseRawProfileEntitlements(p.attributes.ProfileContent)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitrise-io/go-xcode/profileutil"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/devportalclient/appstoreconnect"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProfileDownloader_ListProvisioningProfiles(t *testing.T) {
+	installed := fakeProfileProvider{profiles: []profileutil.ProvisioningProfileInfoModel{
+		{UUID: "uuid-1", Name: "installed copy"},
+		{UUID: "uuid-2", Name: "installed"},
+	}}
+	downloader := newProfileDownloader(context.Background(), nil, http.DefaultClient, installed, fakeProfileConverter{})
+	downloader.infos = []profileutil.ProvisioningProfileInfoModel{{UUID: "uuid-1", Name: "downloaded"}}
+	downloader.contents = map[string][]byte{"uuid-1": []byte("content")}
+
+	infos, err := downloader.ListProvisioningProfiles()
+	assert.NoError(t, err)
+	assert.Equal(t, []profileutil.ProvisioningProfileInfoModel{
+		{UUID: "uuid-1", Name: "downloaded"},
+		{UUID: "uuid-2", Name: "installed"},
+	}, infos)
+}
+
+func TestProfileDownloader_ProfileInfoToProfile(t *testing.T) {
+	downloader := newProfileDownloader(context.Background(), nil, http.DefaultClient, fakeProfileProvider{}, fakeProfileConverter{})
+	downloader.contents = map[string][]byte{"uuid-1": []byte("content")}
+
+	profile, err := downloader.ProfileInfoToProfile(profileutil.ProvisioningProfileInfoModel{UUID: "uuid-1", Name: "downloaded", BundleID: "io.bitrise.app", Type: profileutil.ProfileTypeIos})
+	assert.NoError(t, err)
+	assert.Equal(t, "content", string(profile.Attributes().ProfileContent))
+	assert.Equal(t, appstoreconnect.IOS, profile.Attributes().Platform)
+	bundleID, err := profile.BundleID()
+	assert.NoError(t, err)
+	assert.Equal(t, "io.bitrise.app", bundleID.Attributes.Identifier)
+
+	profile, err = downloader.ProfileInfoToProfile(profileutil.ProvisioningProfileInfoModel{UUID: "uuid-2", Name: "installed"})
+	assert.NoError(t, err)
+	assert.Equal(t, "uuid-2", profile.Attributes().UUID)
+	assert.Nil(t, profile.Attributes().ProfileContent)
+}
+
+func TestProfileDownloader_InvalidProfile(t *testing.T) {
+	pth := filepath.Join(t.TempDir(), "invalid.mobileprovision")
+	assert.NoError(t, ioutil.WriteFile(pth, []byte("not a profile"), 0600))
+
+	downloader := newProfileDownloader(context.Background(), []string{"file://" + pth}, http.DefaultClient, fakeProfileProvider{}, fakeProfileConverter{})
+	_, err := downloader.ListProvisioningProfiles()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to parse provisioning profile")
+}
+
+func TestProfileDownloader_Canceled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("no request is expected after the Step's context is canceled")
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	downloader := newProfileDownloader(ctx, []string{server.URL + "/profile.mobileprovision"}, http.DefaultClient, fakeProfileProvider{}, fakeProfileConverter{})
+	_, err := downloader.ListProvisioningProfiles()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "context canceled")
+	}
+}
+
+func TestProfileDirProvider(t *testing.T) {
+	dir := t.TempDir()
+	provider := newProfileDirProvider([]string{dir, filepath.Join(dir, "missing")})
+
+	infos, err := provider.ListProvisioningProfiles()
+	assert.NoError(t, err)
+	assert.Empty(t, infos)
+
+	_, err = provider.ProfileInfoToProfile(profileutil.ProvisioningProfileInfoModel{UUID: "uuid-1"})
+	assert.EqualError(t, err, "provisioning profile (uuid-1) not found")
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "invalid.mobileprovision"), []byte("not a profile"), 0600))
+	_, err = provider.ListProvisioningProfiles()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to parse provisioning profile")
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/projectmanager"
+)
+
+// Project applies the code signing settings on the Xcode project.
+// It shares the parsed project with NewTargetAppLayout, unlike projectmanager.Project, which parses the project on its own.
+type Project struct {
+	helper *projectmanager.ProjectHelper
+}
+
+// NewProject parses the scheme's Xcode project.
+func NewProject(projectPath, scheme, configuration string) (Project, error) {
+	helper, err := projectmanager.NewProjectHelper(projectPath, scheme, configuration)
+	if err != nil {
+		return Project{}, err
+	}
+	return Project{helper: helper}, nil
+}
+
+// AppLayout reads the app layout of the scheme's archivable (and optionally UITest) targets.
+func (p Project) AppLayout(uiTestTargets bool) (TargetAppLayout, error) {
+	return NewTargetAppLayout(p.helper, uiTestTargets)
+}
+
+// MainTargetBundleID ...
+func (p Project) MainTargetBundleID() (string, error) {
+	bundleID, err := p.helper.TargetBundleID(p.helper.MainTarget.Name, p.helper.Configuration)
+	if err != nil {
+		return "", fmt.Errorf("failed to read bundle ID for the main target: %s", err)
+	}
+
+	return bundleID, nil
+}
+
+// ForceCodesignAssets applies manual code signing with the ensured certificates and profiles on the archivable targets,
+// and with the development ones on the UITest targets, the same way as projectmanager.Project.ForceCodesignAssets.
+func (p Project) ForceCodesignAssets(distribution autocodesign.DistributionType, codesignAssetsByDistributionType map[autocodesign.DistributionType]autocodesign.AppCodesignAssets) error {
+	fmt.Println()
+	log.Infof("Apply Bitrise managed codesigning on the executable targets")
+	for _, target := range p.helper.ArchivableTargets() {
+		fmt.Println()
+		log.Infof("  Target: %s", target.Name)
+
+		forceCodesignDistribution := distribution
+		if _, isDevelopmentAvailable := codesignAssetsByDistributionType[autocodesign.Development]; isDevelopmentAvailable {
+			forceCodesignDistribution = autocodesign.Development
+		}
+
+		codesignAssets, ok := codesignAssetsByDistributionType[forceCodesignDistribution]
+		if !ok {
+			return fmt.Errorf("no codesign settings ensured for distribution type %s", forceCodesignDistribution)
+		}
+		teamID := codesignAssets.Certificate.TeamID
+
+		targetBundleID, err := p.helper.TargetBundleID(target.Name, p.helper.Configuration)
+		if err != nil {
+			return err
+		}
+		profile, ok := codesignAssets.ArchivableTargetProfilesByBundleID[targetBundleID]
+		if !ok {
+			return fmt.Errorf("no profile ensured for the bundleID %s", targetBundleID)
+		}
+
+		log.Printf("  development Team: %s(%s)", codesignAssets.Certificate.TeamName, teamID)
+		log.Printf("  provisioning Profile: %s", profile.Attributes().Name)
+		log.Printf("  certificate: %s", codesignAssets.Certificate.CommonName)
+
+		if err := p.helper.XcProj.ForceCodeSign(p.helper.Configuration, target.Name, teamID, codesignAssets.Certificate.SHA1Fingerprint, profile.Attributes().UUID); err != nil {
+			return fmt.Errorf("failed to apply code sign settings for target (%s): %s", target.Name, err)
+		}
+	}
+
+	devCodesignAssets, isDevelopmentAvailable := codesignAssetsByDistributionType[autocodesign.Development]
+	if isDevelopmentAvailable && len(devCodesignAssets.UITestTargetProfilesByBundleID) != 0 {
+		fmt.Println()
+		log.Infof("Apply Bitrise managed codesigning on the UITest targets")
+		for _, uiTestTarget := range p.helper.UITestTargets {
+			fmt.Println()
+			log.Infof("  Target: %s", uiTestTarget.Name)
+
+			teamID := devCodesignAssets.Certificate.TeamID
+
+			targetBundleID, err := p.helper.TargetBundleID(uiTestTarget.Name, p.helper.Configuration)
+			if err != nil {
+				return err
+			}
+			profile, ok := devCodesignAssets.UITestTargetProfilesByBundleID[targetBundleID]
+			if !ok {
+				return fmt.Errorf("no profile ensured for the bundleID %s", targetBundleID)
+			}
+
+			log.Printf("  development Team: %s(%s)", devCodesignAssets.Certificate.TeamName, teamID)
+			log.Printf("  provisioning Profile: %s", profile.Attributes().Name)
+			log.Printf("  certificate: %s", devCodesignAssets.Certificate.CommonName)
+
+			for _, c := range uiTestTarget.BuildConfigurationList.BuildConfigurations {
+				if err := p.helper.XcProj.ForceCodeSign(c.Name, uiTestTarget.Name, teamID, devCodesignAssets.Certificate.SHA1Fingerprint, profile.Attributes().UUID); err != nil {
+					return fmt.Errorf("failed to apply code sign settings for target (%s): %s", uiTestTarget.Name, err)
+				}
+			}
+		}
+	}
+
+	if err := p.helper.XcProj.Save(); err != nil {
+		return fmt.Errorf("failed to save project: %s", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/bitrise-io/go-xcode/v2/autocodesign"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/projectmanager"
+	"github.com/bitrise-io/go-xcode/xcodeproject/xcodeproj"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProject_ForceCodesignAssets_MissingDistribution(t *testing.T) {
+	project := Project{helper: &projectmanager.ProjectHelper{
+		MainTarget:    xcodeproj.Target{Name: "App"},
+		Configuration: "Release",
+	}}
+
+	err := project.ForceCodesignAssets(autocodesign.AppStore, map[autocodesign.DistributionType]autocodesign.AppCodesignAssets{})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "no codesign settings ensured for distribution type app-store")
+	}
+}
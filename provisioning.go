@@ -0,0 +1,16 @@
+package main
+
+import (
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/devportalclient/appstoreconnect"
+)
+
+// ProvisioningService implements the App Store Connect API provisioning endpoints
+// which are not covered by appstoreconnect.ProvisioningService.
+type ProvisioningService struct {
+	client *appstoreconnect.Client
+}
+
+// NewProvisioningService ...
+func NewProvisioningService(client *appstoreconnect.Client) ProvisioningService {
+	return ProvisioningService{client: client}
+}
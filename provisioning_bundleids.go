@@ -0,0 +1,18 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/devportalclient/appstoreconnect"
+)
+
+// DeleteBundleID deletes the app ID with the given ID.
+func (s ProvisioningService) DeleteBundleID(id string) error {
+	req, err := s.client.NewRequest(http.MethodDelete, appstoreconnect.BundleIDsEndpoint+"/"+id, nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.client.Do(req, nil)
+	return err
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/devportalclient/appstoreconnect"
+)
+
+// CertificateCreateRequestDataAttributes ...
+type CertificateCreateRequestDataAttributes struct {
+	CertificateType appstoreconnect.CertificateType `json:"certificateType"`
+	CsrContent      string                          `json:"csrContent"`
+}
+
+// CertificateCreateRequestData ...
+type CertificateCreateRequestData struct {
+	Attributes CertificateCreateRequestDataAttributes `json:"attributes"`
+	Type       string                                 `json:"type"`
+}
+
+// CertificateCreateRequest ...
+type CertificateCreateRequest struct {
+	Data CertificateCreateRequestData `json:"data"`
+}
+
+// CertificateResponse ...
+type CertificateResponse struct {
+	Data appstoreconnect.Certificate `json:"data"`
+}
+
+// CreateCertificate creates a certificate from the given certificate signing request (PEM).
+func (s ProvisioningService) CreateCertificate(body CertificateCreateRequest) (*CertificateResponse, error) {
+	req, err := s.client.NewRequest(http.MethodPost, appstoreconnect.CertificatesEndpoint, body)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &CertificateResponse{}
+	if _, err := s.client.Do(req, r); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// RevokeCertificate revokes the certificate with the given ID.
+func (s ProvisioningService) RevokeCertificate(id string) error {
+	req, err := s.client.NewRequest(http.MethodDelete, appstoreconnect.CertificatesEndpoint+"/"+id, nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.client.Do(req, nil)
+	return err
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/devportalclient/appstoreconnect"
+)
+
+// DeviceUpdateRequestDataAttributes ...
+type DeviceUpdateRequestDataAttributes struct {
+	Name   string                 `json:"name,omitempty"`
+	Status appstoreconnect.Status `json:"status,omitempty"`
+}
+
+// DeviceUpdateRequestData ...
+type DeviceUpdateRequestData struct {
+	Attributes DeviceUpdateRequestDataAttributes `json:"attributes"`
+	ID         string                            `json:"id"`
+	Type       string                            `json:"type"`
+}
+
+// DeviceUpdateRequest ...
+type DeviceUpdateRequest struct {
+	Data DeviceUpdateRequestData `json:"data"`
+}
+
+// NewDeviceUpdateRequest ...
+func NewDeviceUpdateRequest(id string, attributes DeviceUpdateRequestDataAttributes) DeviceUpdateRequest {
+	return DeviceUpdateRequest{
+		Data: DeviceUpdateRequestData{
+			Attributes: attributes,
+			ID:         id,
+			Type:       "devices",
+		},
+	}
+}
+
+// UpdateDevice renames, enables or disables a device.
+func (s ProvisioningService) UpdateDevice(id string, body DeviceUpdateRequest) (*appstoreconnect.DeviceResponse, error) {
+	req, err := s.client.NewRequest(http.MethodPatch, appstoreconnect.DevicesEndpoint+"/"+id, body)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &appstoreconnect.DeviceResponse{}
+	if _, err := s.client.Do(req, r); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/devportalclient/appstoreconnect"
+)
+
+// Orphans are the Bitrise managed profiles and app IDs which belong to none of the live bundle IDs
+type Orphans struct {
+	Profiles  []appstoreconnect.Profile
+	BundleIDs []appstoreconnect.BundleID
+}
+
+// Pruner deletes the Bitrise managed profiles and app IDs of bundle IDs which are no longer used.
+// If plan is not nil, the Developer Portal changes are recorded into it instead of being executed.
+type Pruner struct {
+	client   *appstoreconnect.Client
+	service  ProvisioningService
+	profiles BitriseProfileMatcher
+	plan     *PortalPlan
+}
+
+// NewPruner ...
+func NewPruner(client *appstoreconnect.Client, profiles BitriseProfileMatcher, plan *PortalPlan) Pruner {
+	return Pruner{
+		client:   client,
+		service:  NewProvisioningService(client),
+		profiles: profiles,
+		plan:     plan,
+	}
+}
+
+// FindOrphans lists the Bitrise managed profiles and app IDs, which belong to none of the live bundle IDs.
+func (p Pruner) FindOrphans(liveBundleIDs []string) (Orphans, error) {
+	live, err := newLiveBundleIDs(liveBundleIDs)
+	if err != nil {
+		return Orphans{}, err
+	}
+
+	profiles, err := p.profiles.ListProfiles()
+	if err != nil {
+		return Orphans{}, err
+	}
+
+	bundleIDs, err := p.listBitriseBundleIDs()
+	if err != nil {
+		return Orphans{}, err
+	}
+
+	return Orphans{
+		Profiles:  orphanProfiles(profiles, live),
+		BundleIDs: orphanBundleIDs(bundleIDs, live),
+	}, nil
+}
+
+// Prune deletes the orphan profiles, then the orphan app IDs.
+func (p Pruner) Prune(orphans Orphans) error {
+	for _, profile := range orphans.Profiles {
+		if p.plan != nil {
+			p.plan.record(PortalChange{Action: DeleteProfileAction, ID: profile.ID, Name: profile.Attributes.Name})
+			continue
+		}
+
+		if err := p.client.Provisioning.DeleteProfile(profile.ID); err != nil {
+			return fmt.Errorf("failed to delete profile %s: %s", profile.Attributes.Name, err)
+		}
+		log.Donef("Profile deleted: %s", profile.Attributes.Name)
+	}
+
+	for _, bundleID := range orphans.BundleIDs {
+		if p.plan != nil {
+			p.plan.record(PortalChange{Action: DeleteBundleIDAction, ID: bundleID.ID, Name: bundleID.Attributes.Name, BundleID: bundleID.Attributes.Identifier})
+			continue
+		}
+
+		if err := p.service.DeleteBundleID(bundleID.ID); err != nil {
+			return fmt.Errorf("failed to delete app ID %s: %s", bundleID.Attributes.Name, err)
+		}
+		log.Donef("App ID deleted: %s (%s)", bundleID.Attributes.Name, bundleID.Attributes.Identifier)
+	}
+
+	return nil
+}
+
+func (p Pruner) listBitriseBundleIDs() ([]appstoreconnect.BundleID, error) {
+	pager := NewPager(p.client, "bundleIds", PagerOptions{})
+	var bundleIDs []appstoreconnect.BundleID
+	for pager.HasNext() {
+		var response appstoreconnect.BundleIdsResponse
+		if err := pager.Next(&response); err != nil {
+			return nil, fmt.Errorf("failed to list app IDs: %s", err)
+		}
+
+		for _, bundleID := range response.Data {
+			if bundleID.Attributes.Name == bitriseAppIDName(bundleID.Attributes.Identifier) {
+				bundleIDs = append(bundleIDs, bundleID)
+			}
+		}
+	}
+	return bundleIDs, nil
+}
+
+// liveBundleIDs are the bundle IDs in use, including the wildcard bundle IDs used for UITest targets.
+type liveBundleIDs map[string]bool
+
+func newLiveBundleIDs(bundleIDs []string) (liveBundleIDs, error) {
+	live := liveBundleIDs{}
+	for _, bundleID := range bundleIDs {
+		live[bundleID] = true
+		if strings.HasSuffix(bundleID, ".*") {
+			continue
+		}
+
+		wildcardBundleID, err := autocodesign.CreateWildcardBundleID(bundleID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bundle ID (%s): %s", bundleID, err)
+		}
+		live[wildcardBundleID] = true
+	}
+	return live, nil
+}
+
+// bundleIDsOfAppLayout returns the archivable and UITest target bundle IDs.
+func bundleIDsOfAppLayout(appLayout autocodesign.AppLayout) []string {
+	var bundleIDs []string
+	for bundleID := range appLayout.EntitlementsByArchivableTargetBundleID {
+		bundleIDs = append(bundleIDs, bundleID)
+	}
+	bundleIDs = append(bundleIDs, appLayout.UITestTargetBundleIDs...)
+	sort.Strings(bundleIDs)
+	return bundleIDs
+}
+
+func orphanProfiles(profiles []BitriseProfile, live liveBundleIDs) []appstoreconnect.Profile {
+	var orphans []appstoreconnect.Profile
+	for _, profile := range profiles {
+		if !live[profile.BundleID] {
+			orphans = append(orphans, profile.Profile)
+		}
+	}
+	return orphans
+}
+
+func orphanBundleIDs(bundleIDs []appstoreconnect.BundleID, live liveBundleIDs) []appstoreconnect.BundleID {
+	var orphans []appstoreconnect.BundleID
+	for _, bundleID := range bundleIDs {
+		if !live[bundleID.Attributes.Identifier] {
+			orphans = append(orphans, bundleID)
+		}
+	}
+	return orphans
+}
+
+func printOrphans(orphans Orphans) {
+	fmt.Println()
+	if len(orphans.Profiles) == 0 && len(orphans.BundleIDs) == 0 {
+		log.Donef("No stale Bitrise managed profiles or app IDs found")
+		return
+	}
+
+	log.Warnf("Stale Bitrise managed profiles (%d):", len(orphans.Profiles))
+	for _, profile := range orphans.Profiles {
+		log.Printf("- %s (ID: %s)", profile.Attributes.Name, profile.ID)
+	}
+
+	log.Warnf("Stale Bitrise managed app IDs (%d):", len(orphans.BundleIDs))
+	for _, bundleID := range orphans.BundleIDs {
+		log.Printf("- %s (bundle ID: %s, ID: %s)", bundleID.Attributes.Name, bundleID.Attributes.Identifier, bundleID.ID)
+	}
+}
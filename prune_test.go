@@ -0,0 +1,160 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/go-xcode/v2/autocodesign"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/devportalclient/appstoreconnect"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindOrphans(t *testing.T) {
+	live, err := newLiveBundleIDs([]string{"io.bitrise.app", "io.bitrise.app.uitests"})
+	assert.NoError(t, err)
+
+	profile := func(name string) appstoreconnect.Profile {
+		return appstoreconnect.Profile{ID: name, Attributes: appstoreconnect.ProfileAttributes{Name: name}}
+	}
+	profiles := bitriseProfiles(t,
+		profile("Bitrise iOS development - (io.bitrise.app)"),
+		profile("Bitrise iOS app-store - (io.bitrise.app)"),
+		profile("Wildcard Bitrise iOS development - (io.bitrise.app)"),
+		profile("Bitrise iOS development - (io.bitrise.old)"),
+		profile("Wildcard Bitrise iOS development - (io.bitrise.old)"),
+		profile("Custom profile"),
+	)
+	assert.Equal(t, []appstoreconnect.Profile{
+		profile("Bitrise iOS development - (io.bitrise.old)"),
+		profile("Wildcard Bitrise iOS development - (io.bitrise.old)"),
+	}, orphanProfiles(profiles, live))
+
+	bundleID := func(identifier string) appstoreconnect.BundleID {
+		return appstoreconnect.BundleID{ID: identifier, Attributes: appstoreconnect.BundleIDAttributes{Identifier: identifier, Name: bitriseAppIDName(identifier)}}
+	}
+	bundleIDs := []appstoreconnect.BundleID{
+		bundleID("io.bitrise.app"),
+		bundleID("io.bitrise.app.*"),
+		bundleID("io.bitrise.old"),
+	}
+	assert.Equal(t, []appstoreconnect.BundleID{bundleID("io.bitrise.old")}, orphanBundleIDs(bundleIDs, live))
+}
+
+func TestFindOrphans_MacCatalyst(t *testing.T) {
+	appLayout := TargetAppLayout{
+		AppLayout: autocodesign.AppLayout{
+			Platform:                               autocodesign.IOS,
+			EntitlementsByArchivableTargetBundleID: map[string]autocodesign.Entitlements{"io.bitrise.app": nil},
+		},
+	}
+	signedAppLayouts, err := NewSignedAppLayouts(appLayout, true, true)
+	assert.NoError(t, err)
+	live, err := newLiveBundleIDs(signedAppLayouts.BundleIDs())
+	assert.NoError(t, err)
+
+	profile := func(name string) appstoreconnect.Profile {
+		return appstoreconnect.Profile{ID: name, Attributes: appstoreconnect.ProfileAttributes{Name: name}}
+	}
+	profiles := bitriseProfiles(t,
+		profile("Bitrise iOS development - (io.bitrise.app)"),
+		profile("Bitrise macCatalyst development - (maccatalyst.io.bitrise.app)"),
+		profile("Bitrise macCatalyst development - (maccatalyst.io.bitrise.old)"),
+	)
+	assert.Equal(t, []appstoreconnect.Profile{
+		profile("Bitrise macCatalyst development - (maccatalyst.io.bitrise.old)"),
+	}, orphanProfiles(profiles, live))
+
+	bundleID := func(identifier string) appstoreconnect.BundleID {
+		return appstoreconnect.BundleID{ID: identifier, Attributes: appstoreconnect.BundleIDAttributes{Identifier: identifier, Name: bitriseAppIDName(identifier)}}
+	}
+	bundleIDs := []appstoreconnect.BundleID{
+		bundleID("io.bitrise.app"),
+		bundleID("maccatalyst.io.bitrise.app"),
+	}
+	assert.Empty(t, orphanBundleIDs(bundleIDs, live))
+}
+
+// bitriseProfiles keeps the profiles with the default Bitrise names.
+func bitriseProfiles(t *testing.T, profiles ...appstoreconnect.Profile) []BitriseProfile {
+	matcher := NewBitriseProfileMatcher(nil, nil, "", "")
+	var bitriseProfiles []BitriseProfile
+	for _, profile := range profiles {
+		bundleID, ok, err := matcher.BundleID(profile)
+		assert.NoError(t, err)
+		if ok {
+			bitriseProfiles = append(bitriseProfiles, BitriseProfile{Profile: profile, BundleID: bundleID})
+		}
+	}
+	return bitriseProfiles
+}
+
+// TestPruner_FindOrphans_CreatedAppID checks that the app IDs created by autocodesign, with or without the
+// appIDNamingDevPortalClient, are recognized as Bitrise managed ones.
+func TestPruner_FindOrphans_CreatedAppID(t *testing.T) {
+	identity := newTestIdentity(t, "Apple Development: Bitrise Bot")
+	for _, tt := range []struct {
+		name        string
+		appIDNaming bool
+	}{
+		{name: "autocodesign app ID name", appIDNaming: false},
+		{name: "Step app ID name", appIDNaming: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeAppStoreConnect(t)
+			server.AddCertificate(appstoreconnect.IOSDevelopment, identity)
+
+			client := newFakeDevPortalClient(t, server, "")
+			if tt.appIDNaming {
+				client = newAppIDNamingDevPortalClient(client)
+			}
+			_, err := ensureFakeCodesignAssets(client, identity)
+			assert.NoError(t, err)
+
+			apiClient := newFakeAPIClient(t, server)
+			orphans, err := NewPruner(apiClient, NewBitriseProfileMatcher(apiClient, nil, "", ""), nil).FindOrphans([]string{"io.bitrise.other"})
+			assert.NoError(t, err)
+			if assert.Len(t, orphans.BundleIDs, 1) {
+				assert.Equal(t, "io.bitrise.app", orphans.BundleIDs[0].Attributes.Identifier)
+				assert.Equal(t, "Bitrise io bitrise app", orphans.BundleIDs[0].Attributes.Name)
+			}
+		})
+	}
+}
+
+func TestPruner_FindOrphans_TemplatedProfile(t *testing.T) {
+	identity := newTestIdentity(t, "Apple Development: Bitrise Bot")
+	template, err := NewProfileNameTemplate(`CI {{.Platform}} {{.Distribution}} {{.BundleID}} {{.TeamID}}`)
+	assert.NoError(t, err)
+
+	server := newFakeAppStoreConnect(t)
+	server.AddCertificate(appstoreconnect.IOSDevelopment, identity)
+	client := newAppIDNamingDevPortalClient(newProfileNamingDevPortalClient(newFakeDevPortalClient(t, server, ""), template, "", ""))
+	_, err = ensureFakeCodesignAssets(client, identity)
+	assert.NoError(t, err)
+
+	// The same name on an app ID which is not managed by Bitrise
+	customBundleIDID := server.AddBundleID("io.bitrise.custom")
+	server.AddProfile(fakeProfile{Name: "CI iOS development io.bitrise.custom TEAMID", Type: appstoreconnect.IOSAppDevelopment, BundleIDID: customBundleIDID, Expiry: time.Now().AddDate(1, 0, 0)})
+	server.AddProfile(fakeProfile{Name: "Custom profile", Type: appstoreconnect.IOSAppDevelopment, BundleIDID: customBundleIDID, Expiry: time.Now().AddDate(1, 0, 0)})
+
+	apiClient := newFakeAPIClient(t, server)
+	t.Run("without the template", func(t *testing.T) {
+		orphans, err := NewPruner(apiClient, NewBitriseProfileMatcher(apiClient, nil, "", ""), nil).FindOrphans([]string{"io.bitrise.other"})
+		assert.NoError(t, err)
+		assert.Empty(t, orphans.Profiles)
+	})
+
+	t.Run("with the template", func(t *testing.T) {
+		orphans, err := NewPruner(apiClient, NewBitriseProfileMatcher(apiClient, &template, "", ""), nil).FindOrphans([]string{"io.bitrise.other"})
+		assert.NoError(t, err)
+		if assert.Len(t, orphans.Profiles, 1) {
+			assert.Equal(t, "CI iOS development io.bitrise.app TEAMID", orphans.Profiles[0].Attributes.Name)
+		}
+	})
+
+	t.Run("live bundle ID", func(t *testing.T) {
+		orphans, err := NewPruner(apiClient, NewBitriseProfileMatcher(apiClient, &template, "TEAMID", ""), nil).FindOrphans([]string{"io.bitrise.app"})
+		assert.NoError(t, err)
+		assert.Empty(t, orphans.Profiles)
+	})
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/devportalclient/appstoreconnect"
+)
+
+// rateLimitHeader is the App Store Connect API's rate limit response header,
+// for example: `X-Rate-Limit: user-hour-lim:3600;user-hour-rem:3545;`
+const rateLimitHeader = "X-Rate-Limit"
+
+// rateLimitThrottleRatio is the ratio of the hourly limit, below which the remaining requests are throttled
+const rateLimitThrottleRatio = 0.1
+
+// rateLimiter tracks the App Store Connect API hourly request quota of the API key.
+// When the remaining quota drops below rateLimitThrottleRatio of the hourly limit,
+// it spreads the requests evenly over the hour, instead of running into 429 (Too Many Requests) responses mid-run.
+type rateLimiter struct {
+	sleep func(ctx context.Context, d time.Duration) error
+
+	mu        sync.Mutex
+	limit     int
+	remaining int
+	requests  int
+	throttled bool
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{sleep: sleepContext}
+}
+
+// Remaining returns the remaining and the hourly limit of the requests, as reported by the last response.
+// ok is false if no response reported the rate limit yet.
+func (l *rateLimiter) Remaining() (remaining int, limit int, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.remaining, l.limit, l.limit > 0
+}
+
+// Requests returns the number of requests sent, a request and its retries count as one.
+func (l *rateLimiter) Requests() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.requests
+}
+
+// Summary describes the quota consumed by the run.
+func (l *rateLimiter) Summary() string {
+	requests := l.Requests()
+	remaining, limit, ok := l.Remaining()
+	if !ok {
+		return fmt.Sprintf("App Store Connect API requests sent: %d", requests)
+	}
+	return fmt.Sprintf("App Store Connect API requests sent: %d, remaining hourly quota: %d of %d", requests, remaining, limit)
+}
+
+// Transport wraps the transport to throttle its requests and to track the rate limit of its responses.
+func (l *rateLimiter) Transport(transport http.RoundTripper) http.RoundTripper {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return rateLimitedTransport{limiter: l, transport: transport}
+}
+
+// delay returns how long to wait before the next request, it is zero while the remaining quota is above the threshold.
+func (l *rateLimiter) delay() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.limit == 0 || float64(l.remaining) >= float64(l.limit)*rateLimitThrottleRatio {
+		return 0
+	}
+
+	delay := time.Hour / time.Duration(l.limit)
+	if !l.throttled {
+		log.Warnf("App Store Connect API quota is running low (%d of %d requests remaining this hour), sending a request every %s", l.remaining, l.limit, delay)
+		l.throttled = true
+	}
+	return delay
+}
+
+func (l *rateLimiter) update(resp *http.Response) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.requests++
+	if limit, remaining, ok := parseRateLimit(resp.Header.Get(rateLimitHeader)); ok {
+		l.limit = limit
+		l.remaining = remaining
+	}
+}
+
+type rateLimitedTransport struct {
+	limiter   *rateLimiter
+	transport http.RoundTripper
+}
+
+// RoundTrip ...
+func (t rateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if delay := t.limiter.delay(); delay > 0 {
+		if err := t.limiter.sleep(req.Context(), delay); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := t.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	t.limiter.update(resp)
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		log.Warnf("App Store Connect API rate limit exceeded (%s)", t.limiter.Summary())
+	}
+	return resp, nil
+}
+
+// newRateLimitedHTTPClient returns appstoreconnect.NewRetryableHTTPClient with its requests throttled by the rate limiter.
+// The retries, including the ones of the 429 (Too Many Requests) responses after their Retry-After delay,
+// are left to the retryable client, a request and its retries are throttled and counted once.
+func newRateLimitedHTTPClient(limiter *rateLimiter) *http.Client {
+	client := appstoreconnect.NewRetryableHTTPClient()
+	client.Transport = limiter.Transport(client.Transport)
+	return client
+}
+
+// parseRateLimit parses the X-Rate-Limit header, for example: `user-hour-lim:3600;user-hour-rem:3545;`
+func parseRateLimit(header string) (limit int, remaining int, ok bool) {
+	var hasLimit, hasRemaining bool
+	for _, field := range strings.Split(header, ";") {
+		split := strings.SplitN(strings.TrimSpace(field), ":", 2)
+		if len(split) != 2 {
+			continue
+		}
+
+		value, err := strconv.Atoi(strings.TrimSpace(split[1]))
+		if err != nil {
+			continue
+		}
+
+		switch strings.TrimSpace(split[0]) {
+		case "user-hour-lim":
+			limit, hasLimit = value, true
+		case "user-hour-rem":
+			remaining, hasRemaining = value, true
+		}
+	}
+
+	if !hasLimit || !hasRemaining || limit <= 0 {
+		return 0, 0, false
+	}
+	return limit, remaining, true
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
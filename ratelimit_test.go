@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/go-xcode/appleauth"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/devportalclient/appstoreconnect"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseRateLimit(t *testing.T) {
+	tests := []struct {
+		name          string
+		header        string
+		wantLimit     int
+		wantRemaining int
+		wantOK        bool
+	}{
+		{name: "Apple's format", header: "user-hour-lim:3600;user-hour-rem:3545;", wantLimit: 3600, wantRemaining: 3545, wantOK: true},
+		{name: "spaces and unknown fields", header: "user-hour-lim: 3500; user-minute-lim:100; user-hour-rem: 0", wantLimit: 3500, wantRemaining: 0, wantOK: true},
+		{name: "missing remaining", header: "user-hour-lim:3600;", wantOK: false},
+		{name: "invalid value", header: "user-hour-lim:many;user-hour-rem:10;", wantOK: false},
+		{name: "empty", header: "", wantOK: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limit, remaining, ok := parseRateLimit(tt.header)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.wantLimit, limit)
+			assert.Equal(t, tt.wantRemaining, remaining)
+		})
+	}
+}
+
+func TestRateLimiter_Throttles(t *testing.T) {
+	remaining := 3000
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(rateLimitHeader, fmt.Sprintf("user-hour-lim:3600;user-hour-rem:%d;", remaining))
+	}))
+	defer server.Close()
+
+	var delays []time.Duration
+	limiter := newRateLimiter()
+	limiter.sleep = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return nil
+	}
+	client := &http.Client{Transport: limiter.Transport(nil)}
+
+	get := func() {
+		resp, err := client.Get(server.URL)
+		assert.NoError(t, err)
+		assert.NoError(t, resp.Body.Close())
+	}
+
+	get()
+	get()
+	assert.Empty(t, delays, "the quota is above the threshold")
+
+	remaining = 100
+	get()
+	assert.Empty(t, delays, "the low quota is reported by the response")
+	get()
+	assert.Equal(t, []time.Duration{time.Second}, delays, "one request per second keeps under 3600 requests per hour")
+
+	got, limit, ok := limiter.Remaining()
+	assert.True(t, ok)
+	assert.Equal(t, 100, got)
+	assert.Equal(t, 3600, limit)
+	assert.Equal(t, 4, limiter.Requests())
+	assert.Equal(t, "App Store Connect API requests sent: 4, remaining hourly quota: 100 of 3600", limiter.Summary())
+}
+
+func TestRateLimiter_CanceledWhileThrottled(t *testing.T) {
+	limiter := newRateLimiter()
+	limiter.limit, limiter.remaining = 3600, 0
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://127.0.0.1", nil)
+	assert.NoError(t, err)
+
+	_, err = limiter.Transport(nil).RoundTrip(req)
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 0, limiter.Requests())
+}
+
+func TestNewRateLimitedHTTPClient_RetriesTooManyRequests(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+
+	limiter := newRateLimiter()
+	resp, err := newRateLimitedHTTPClient(limiter).Get(server.URL)
+	assert.NoError(t, err)
+	assert.NoError(t, resp.Body.Close())
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 2, attempts)
+	assert.Equal(t, 1, limiter.Requests())
+}
+
+func TestCreateClient_RateLimit(t *testing.T) {
+	identity := newTestIdentity(t, "Apple Development: Bitrise Bot")
+	server := newFakeAppStoreConnect(t)
+	server.RateLimit = 3600
+	server.AddCertificate(appstoreconnect.IOSDevelopment, identity)
+	server.AddDevice("00008030-000A", appstoreconnect.Iphone)
+
+	limiter := newRateLimiter()
+	client, _, err := createClient(
+		context.Background(),
+		[]appleauth.Source{&appleauth.InputAPIKeySource{}},
+		appleauth.Inputs{APIIssuer: "fake-issuer", APIKeyPath: "file://" + writeFakeAPIKey(t)},
+		"", nil, apiClientOptions{BaseURL: server.URL, RateLimiter: limiter},
+	)
+	assert.NoError(t, err)
+
+	_, err = ensureFakeCodesignAssets(client, identity)
+	assert.NoError(t, err)
+
+	requests := len(server.Requests())
+	assert.Equal(t, requests, limiter.Requests())
+	remaining, limit, ok := limiter.Remaining()
+	assert.True(t, ok)
+	assert.Equal(t, 3600, limit)
+	assert.Equal(t, 3600-requests, remaining)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"time"
+
+	"github.com/bitrise-io/go-xcode/certificateutil"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/devportalclient/appstoreconnect"
+)
+
+// AssetSource tells where a code signing asset came from
+type AssetSource string
+
+// AssetSources ...
+const (
+	LocalAssetSource     AssetSource = "local"
+	GeneratedAssetSource AssetSource = "generated"
+)
+
+// TargetKind ...
+type TargetKind string
+
+// TargetKinds ...
+const (
+	ArchivableTarget TargetKind = "archivable"
+	UITestTarget     TargetKind = "uitest"
+)
+
+// CertificateReport ...
+type CertificateReport struct {
+	CommonName      string    `json:"common_name"`
+	Serial          string    `json:"serial"`
+	SHA1Fingerprint string    `json:"sha1_fingerprint"`
+	TeamID          string    `json:"team_id"`
+	TeamName        string    `json:"team_name"`
+	Expiry          time.Time `json:"expiry"`
+}
+
+// ProfileReport ...
+type ProfileReport struct {
+	BundleID   string                      `json:"bundle_id"`
+	TargetKind TargetKind                  `json:"target_kind"`
+	Name       string                      `json:"name"`
+	ID         string                      `json:"id,omitempty"`
+	UUID       string                      `json:"uuid"`
+	Type       appstoreconnect.ProfileType `json:"type,omitempty"`
+	Expiry     time.Time                   `json:"expiry"`
+	Source     AssetSource                 `json:"source"`
+}
+
+// CodesignAssetsReport ...
+type CodesignAssetsReport struct {
+	DistributionType autocodesign.DistributionType `json:"distribution_type"`
+	Certificate      CertificateReport             `json:"certificate"`
+	Profiles         []ProfileReport               `json:"profiles"`
+}
+
+// RunReport describes every code signing asset ensured by the Step
+type RunReport struct {
+	DistributionType autocodesign.DistributionType `json:"distribution_type"`
+	CodesignAssets   []CodesignAssetsReport        `json:"codesign_assets"`
+}
+
+// NewRunReport ...
+func NewRunReport(distribution autocodesign.DistributionType, codesignAssetsByDistributionType map[autocodesign.DistributionType]autocodesign.AppCodesignAssets, localProfileUUIDs map[string]bool) RunReport {
+	report := RunReport{DistributionType: distribution}
+	report.AddCodesignAssets(codesignAssetsByDistributionType, localProfileUUIDs)
+	return report
+}
+
+// AddCodesignAssets adds the code signing assets of a separately signed app layout, like the Mac Catalyst one.
+// They are listed separately, as their bundle IDs may be the same as the ones of the already added assets.
+func (r *RunReport) AddCodesignAssets(codesignAssetsByDistributionType map[autocodesign.DistributionType]autocodesign.AppCodesignAssets, localProfileUUIDs map[string]bool) {
+	var distrTypes []autocodesign.DistributionType
+	for distrType := range codesignAssetsByDistributionType {
+		distrTypes = append(distrTypes, distrType)
+	}
+	sort.Slice(distrTypes, func(i, j int) bool { return distrTypes[i] < distrTypes[j] })
+
+	for _, distrType := range distrTypes {
+		assets := codesignAssetsByDistributionType[distrType]
+		assetsReport := CodesignAssetsReport{
+			DistributionType: distrType,
+			Certificate:      newCertificateReport(assets.Certificate),
+		}
+
+		assetsReport.Profiles = append(assetsReport.Profiles, newProfileReports(assets.ArchivableTargetProfilesByBundleID, ArchivableTarget, localProfileUUIDs)...)
+		assetsReport.Profiles = append(assetsReport.Profiles, newProfileReports(assets.UITestTargetProfilesByBundleID, UITestTarget, localProfileUUIDs)...)
+
+		r.CodesignAssets = append(r.CodesignAssets, assetsReport)
+	}
+}
+
+// WriteToFile ...
+func (r RunReport) WriteToFile(pth string) error {
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal report: %s", err)
+	}
+
+	return ioutil.WriteFile(pth, b, 0600)
+}
+
+func newCertificateReport(certificate certificateutil.CertificateInfoModel) CertificateReport {
+	return CertificateReport{
+		CommonName:      certificate.CommonName,
+		Serial:          certificate.Serial,
+		SHA1Fingerprint: certificate.SHA1Fingerprint,
+		TeamID:          certificate.TeamID,
+		TeamName:        certificate.TeamName,
+		Expiry:          certificate.EndDate,
+	}
+}
+
+func newProfileReports(profilesByBundleID map[string]autocodesign.Profile, kind TargetKind, localProfileUUIDs map[string]bool) []ProfileReport {
+	var bundleIDs []string
+	for bundleID := range profilesByBundleID {
+		bundleIDs = append(bundleIDs, bundleID)
+	}
+	sort.Strings(bundleIDs)
+
+	var reports []ProfileReport
+	for _, bundleID := range bundleIDs {
+		profile := profilesByBundleID[bundleID]
+		attributes := profile.Attributes()
+
+		source := GeneratedAssetSource
+		if localProfileUUIDs[attributes.UUID] {
+			source = LocalAssetSource
+		}
+
+		reports = append(reports, ProfileReport{
+			BundleID:   bundleID,
+			TargetKind: kind,
+			Name:       attributes.Name,
+			ID:         profile.ID(),
+			UUID:       attributes.UUID,
+			Type:       attributes.ProfileType,
+			Expiry:     time.Time(attributes.ExpirationDate),
+			Source:     source,
+		})
+	}
+
+	return reports
+}
+
+// localAssetRecorder remembers which profiles were found locally,
+// to tell them apart from the ones generated on the Developer Portal.
+type localAssetRecorder struct {
+	autocodesign.LocalCodeSignAssetManager
+
+	profileUUIDs map[string]bool
+}
+
+func newLocalAssetRecorder(manager autocodesign.LocalCodeSignAssetManager) localAssetRecorder {
+	return localAssetRecorder{
+		LocalCodeSignAssetManager: manager,
+		profileUUIDs:              map[string]bool{},
+	}
+}
+
+// FindCodesignAssets ...
+func (r localAssetRecorder) FindCodesignAssets(appLayout autocodesign.AppLayout, distrType autocodesign.DistributionType, certsByType map[appstoreconnect.CertificateType][]autocodesign.Certificate, deviceIDs []string, minProfileDaysValid int) (*autocodesign.AppCodesignAssets, *autocodesign.AppLayout, error) {
+	assets, missingAppLayout, err := r.LocalCodeSignAssetManager.FindCodesignAssets(appLayout, distrType, certsByType, deviceIDs, minProfileDaysValid)
+	if err != nil || assets == nil {
+		return assets, missingAppLayout, err
+	}
+
+	for _, profile := range assets.ArchivableTargetProfilesByBundleID {
+		r.profileUUIDs[profile.Attributes().UUID] = true
+	}
+	for _, profile := range assets.UITestTargetProfilesByBundleID {
+		r.profileUUIDs[profile.Attributes().UUID] = true
+	}
+
+	return assets, missingAppLayout, nil
+}
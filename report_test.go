@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/bitrise-io/go-xcode/certificateutil"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/devportalclient/appstoreconnect"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewRunReport(t *testing.T) {
+	profile := func(name, uuid string) autocodesign.Profile {
+		return plannedProfile{attributes: appstoreconnect.ProfileAttributes{Name: name, UUID: uuid}}
+	}
+
+	assets := map[autocodesign.DistributionType]autocodesign.AppCodesignAssets{
+		autocodesign.Development: {
+			ArchivableTargetProfilesByBundleID: map[string]autocodesign.Profile{
+				"io.bitrise.app":       profile("app", "uuid-1"),
+				"io.bitrise.app.share": profile("share", "uuid-2"),
+			},
+			UITestTargetProfilesByBundleID: map[string]autocodesign.Profile{
+				"io.bitrise.app.uitests": profile("uitests", "uuid-3"),
+			},
+			Certificate: certificateutil.CertificateInfoModel{CommonName: "Apple Development: Bitrise Bot", Serial: "1", TeamID: "TEAM"},
+		},
+	}
+
+	report := NewRunReport(autocodesign.Development, assets, map[string]bool{"uuid-2": true})
+
+	assert.Equal(t, 1, len(report.CodesignAssets))
+	got := report.CodesignAssets[0]
+	assert.Equal(t, "TEAM", got.Certificate.TeamID)
+	assert.Equal(t, []ProfileReport{
+		{BundleID: "io.bitrise.app", TargetKind: ArchivableTarget, Name: "app", UUID: "uuid-1", Source: GeneratedAssetSource},
+		{BundleID: "io.bitrise.app.share", TargetKind: ArchivableTarget, Name: "share", UUID: "uuid-2", Source: LocalAssetSource},
+		{BundleID: "io.bitrise.app.uitests", TargetKind: UITestTarget, Name: "uitests", UUID: "uuid-3", Source: GeneratedAssetSource},
+	}, got.Profiles)
+}
+
+func TestRunReport_AddCodesignAssets(t *testing.T) {
+	profile := func(name, uuid string, profileType appstoreconnect.ProfileType) autocodesign.Profile {
+		return plannedProfile{attributes: appstoreconnect.ProfileAttributes{Name: name, UUID: uuid, ProfileType: profileType}}
+	}
+	certificate := certificateutil.CertificateInfoModel{CommonName: "Apple Development: Bitrise Bot", Serial: "1", TeamID: "TEAM"}
+
+	report := NewRunReport(autocodesign.Development, map[autocodesign.DistributionType]autocodesign.AppCodesignAssets{
+		autocodesign.Development: {
+			ArchivableTargetProfilesByBundleID: map[string]autocodesign.Profile{"io.bitrise.app": profile("ios", "uuid-1", appstoreconnect.IOSAppDevelopment)},
+			Certificate:                        certificate,
+		},
+	}, nil)
+	// the Mac Catalyst app shares the bundle ID of the iOS app
+	report.AddCodesignAssets(map[autocodesign.DistributionType]autocodesign.AppCodesignAssets{
+		autocodesign.Development: {
+			ArchivableTargetProfilesByBundleID: map[string]autocodesign.Profile{"io.bitrise.app": profile("catalyst", "uuid-2", MacCatalystAppDevelopment)},
+			Certificate:                        certificate,
+		},
+	}, nil)
+
+	if assert.Len(t, report.CodesignAssets, 2) {
+		assert.Equal(t, []ProfileReport{
+			{BundleID: "io.bitrise.app", TargetKind: ArchivableTarget, Name: "ios", UUID: "uuid-1", Type: appstoreconnect.IOSAppDevelopment, Source: GeneratedAssetSource},
+		}, report.CodesignAssets[0].Profiles)
+		assert.Equal(t, []ProfileReport{
+			{BundleID: "io.bitrise.app", TargetKind: ArchivableTarget, Name: "catalyst", UUID: "uuid-2", Type: MacCatalystAppDevelopment, Source: GeneratedAssetSource},
+		}, report.CodesignAssets[1].Profiles)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-steputils/input"
+	"github.com/bitrise-io/go-utils/filedownloader"
+	"github.com/bitrise-io/go-xcode/devportalservice"
+)
+
+const defaultTestDeviceType = "ios"
+
+// readTestDevicesFile downloads (or reads) and parses a test device list file.
+// The download is bound by the Step's context.
+func readTestDevicesFile(ctx context.Context, pth string, client *http.Client) ([]devportalservice.TestDevice, error) {
+	if !strings.Contains(pth, "://") {
+		pth = "file://" + pth
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+
+	fileProvider := input.NewFileProvider(filedownloader.NewWithContext(ctx, client))
+	content, err := fileProvider.Contents(pth)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read test devices file (%s): %s", pth, err)
+	}
+
+	devices, err := parseTestDevices(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse test devices file (%s): %s", pth, err)
+	}
+	return devices, nil
+}
+
+// parseTestDevices parses a JSON list of test devices (in the Bitrise Apple Developer connection's format),
+// or Apple's tab-separated device upload format:
+//
+//	Device ID	Device Name	Device Platform
+//	00008030-001A2B3C4D5E6F70	iPhone 11	ios
+func parseTestDevices(content []byte) ([]devportalservice.TestDevice, error) {
+	content = bytes.TrimSpace(content)
+	if bytes.HasPrefix(content, []byte("[")) {
+		var devices []devportalservice.TestDevice
+		if err := json.Unmarshal(content, &devices); err != nil {
+			return nil, err
+		}
+
+		for i, device := range devices {
+			if strings.TrimSpace(device.DeviceID) == "" {
+				return nil, fmt.Errorf("device #%d has no device_identifier", i+1)
+			}
+			if device.DeviceType == "" {
+				devices[i].DeviceType = defaultTestDeviceType
+			}
+		}
+		return devices, nil
+	}
+
+	var devices []devportalservice.TestDevice
+	for i, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		udid := strings.TrimSpace(fields[0])
+		if strings.EqualFold(udid, "Device ID") {
+			continue
+		}
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("line %d: expected tab-separated device ID and name, got: %s", i+1, line)
+		}
+
+		deviceType := defaultTestDeviceType
+		if len(fields) > 2 && strings.TrimSpace(fields[2]) != "" {
+			deviceType = strings.ToLower(strings.TrimSpace(fields[2]))
+		}
+
+		devices = append(devices, devportalservice.TestDevice{
+			DeviceID:   udid,
+			Title:      strings.TrimSpace(fields[1]),
+			DeviceType: deviceType,
+		})
+	}
+
+	return devices, nil
+}
+
+// mergeTestDevices appends the additional devices not already present (by UDID) to the devices.
+func mergeTestDevices(devices, additional []devportalservice.TestDevice) []devportalservice.TestDevice {
+	merged := append([]devportalservice.TestDevice{}, devices...)
+	for _, device := range additional {
+		found := false
+		for _, existing := range merged {
+			if devportalservice.IsEqualUDID(existing.DeviceID, device.DeviceID) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, device)
+		}
+	}
+	return merged
+}
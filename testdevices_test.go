@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bitrise-io/go-xcode/devportalservice"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseTestDevices(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []devportalservice.TestDevice
+		wantErr bool
+	}{
+		{
+			name:    "Apple device upload format",
+			content: "Device ID\tDevice Name\tDevice Platform\n00008030-001A2B3C4D5E6F70\tiPhone 11\tios\r\nA1B2C3D4-E5F6-7890-ABCD-EF1234567890\tMac mini\tmac\n\n",
+			want: []devportalservice.TestDevice{
+				{DeviceID: "00008030-001A2B3C4D5E6F70", Title: "iPhone 11", DeviceType: "ios"},
+				{DeviceID: "A1B2C3D4-E5F6-7890-ABCD-EF1234567890", Title: "Mac mini", DeviceType: "mac"},
+			},
+		},
+		{
+			name:    "Apple device upload format without platform",
+			content: "Device ID\tDevice Name\n00008030-001A2B3C4D5E6F70\tiPhone 11",
+			want: []devportalservice.TestDevice{
+				{DeviceID: "00008030-001A2B3C4D5E6F70", Title: "iPhone 11", DeviceType: "ios"},
+			},
+		},
+		{
+			name:    "JSON list",
+			content: `[{"device_identifier":"00008030-001A2B3C4D5E6F70","title":"iPhone 11"},{"device_identifier":"a1b2","title":"Apple TV","device_type":"tvOS"}]`,
+			want: []devportalservice.TestDevice{
+				{DeviceID: "00008030-001A2B3C4D5E6F70", Title: "iPhone 11", DeviceType: "ios"},
+				{DeviceID: "a1b2", Title: "Apple TV", DeviceType: "tvOS"},
+			},
+		},
+		{
+			name:    "JSON list with missing UDID",
+			content: `[{"title":"iPhone 11"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "missing name",
+			content: "00008030-001A2B3C4D5E6F70",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTestDevices([]byte(tt.content))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_mergeTestDevices(t *testing.T) {
+	devices := []devportalservice.TestDevice{{DeviceID: "00008030-001A2B3C4D5E6F70", Title: "Bitrise"}}
+	additional := []devportalservice.TestDevice{
+		{DeviceID: "00008030001a2b3c4d5e6f70", Title: "File"},
+		{DeviceID: "a1b2", Title: "File"},
+	}
+
+	assert.Equal(t, []devportalservice.TestDevice{
+		{DeviceID: "00008030-001A2B3C4D5E6F70", Title: "Bitrise"},
+		{DeviceID: "a1b2", Title: "File"},
+	}, mergeTestDevices(devices, additional))
+}
+
+func Test_readTestDevicesFile_Canceled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("no request is expected after the Step's context is canceled")
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := readTestDevicesFile(ctx, server.URL+"/devices.txt", http.DefaultClient)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "context canceled")
+	}
+}